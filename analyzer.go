@@ -0,0 +1,411 @@
+package xqml
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// JSONSchemaVersion is the JSON Schema dialect of generated schemas.
+const JSONSchemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+// PathInfo describes the elements or attributes found at a path in sample documents.
+type PathInfo struct {
+	// Path is the path of the element, like "r.x", or of the attribute, like "r.x.@a".
+	Path string `json:"path"`
+	// Name is the name of the element or attribute, without "@".
+	Name string `json:"name"`
+	// Attribute is true for attributes.
+	Attribute bool `json:"attribute,omitempty"`
+	// Count is the number of elements or attributes found.
+	Count int `json:"count"`
+	// Repeated is true if the element is found more than once in the same parent.
+	Repeated bool `json:"repeated,omitempty"`
+	// Optional is true if the element or attribute is missing in some parents.
+	Optional bool `json:"optional,omitempty"`
+	// Attributes is true if the element has attributes.
+	Attributes bool `json:"attributes,omitempty"`
+	// Elements is true if the element has child elements.
+	Elements bool `json:"elements,omitempty"`
+	// Mixed is true if the element has both text and child elements.
+	Mixed bool `json:"mixed,omitempty"`
+	// Empty is true if some elements have no content at all, being decoded as null.
+	Empty bool `json:"empty,omitempty"`
+	// CData is true if some elements have CDATA sections kept in the "#cdata" key, see Decoder.CData.
+	CData bool `json:"cdata,omitempty"`
+	// Type is the cast type of the text or attribute value: CastString, CastInt, CastUint, CastFloat, CastNumber or CastBool,
+	// CastUint being used for integers beyond int64 range and CastNumber for such integers mixed with other numbers.
+	// It is "" if there is no text.
+	Type string `json:"type,omitempty"`
+	// objects is the number of elements decoded as objects
+	objects int
+	// present is the number of parents containing the element or attribute
+	present int
+	parent  *PathInfo
+	// children are the attributes and child elements paths in document order
+	children []*PathInfo
+}
+
+// Config is a decoder configuration inferred by an Analyzer.
+type Config struct {
+	ForceList []string `json:"forceList,omitempty"`
+	CastRules []string `json:"castRules,omitempty"`
+}
+
+// Apply adds the configuration to a decoder, which must not be used yet.
+func (c *Config) Apply(x *Decoder) {
+	x.ForceList = append(x.ForceList, c.ForceList...)
+	x.CastRules = append(x.CastRules, c.CastRules...)
+}
+
+// Analyzer infers the structure of sample documents, to help choosing ForceList paths and cast rules.
+//
+//	a := NewAnalyzer()
+//	err := a.Analyze(NewDecoder(reader))
+//	...
+//	x := NewDecoder(other)
+//	a.Config().Apply(x)
+type Analyzer struct {
+	root  PathInfo
+	paths map[string]*PathInfo
+	order []*PathInfo
+	conv  Convention
+	mixed bool
+	// stack are the elements being analyzed, the first one being the document
+	stack []*analyzed
+}
+
+// analyzed is an element being analyzed.
+type analyzed struct {
+	info   *PathInfo
+	counts map[string]int
+	attrs  bool
+	elems  bool
+	cdata  bool
+	text   string
+}
+
+// NewAnalyzer returns a new analyzer.
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{paths: make(map[string]*PathInfo)}
+}
+
+// Analyze reads all documents of a decoder, as Decode would do, using its namespaces, attributes, convention,
+// whitespace, CDATA sections and limits options. Multiple decoders can be analyzed, the last convention being used
+// for the JSON Schema. Errors found while reading the input are returned as *DecodeError.
+func (a *Analyzer) Analyze(x *Decoder) error {
+	err := x.init()
+	if err != nil {
+		return err
+	}
+	x.raw = false
+	x.setConvention()
+	a.conv = x.conv
+	a.mixed = x.Mixed
+	a.stack = []*analyzed{{info: &a.root, counts: make(map[string]int)}}
+	x.analyzer = a
+	defer func() { x.analyzer = nil }()
+	for {
+		var v any
+		err = x.Decode(&v)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil || !x.Partials {
+			return err
+		}
+	}
+}
+
+// startElement starts the analysis of an element, with its attributes.
+func (a *Analyzer) startElement(x *Decoder, name string, path string, attrs []xml.Attr) {
+	curr := a.stack[len(a.stack)-1]
+	info := a.info(curr.info, path, name)
+	info.Count++
+	curr.counts[name]++
+	curr.elems = true
+	item := &analyzed{info: info, counts: make(map[string]int)}
+	if x.conv.Attributes {
+		for _, attr := range attrs {
+			if key, ok := x.newAttrName(&attr); ok {
+				ai := a.info(info, path+".@"+key, key)
+				ai.Attribute = true
+				ai.Count++
+				ai.present++
+				ai.addType(attr.Value)
+				item.attrs = true
+			}
+		}
+	}
+	a.stack = append(a.stack, item)
+}
+
+// addText adds a text of the element being analyzed, joined to previous texts with sep.
+func (a *Analyzer) addText(text string, sep string) {
+	curr := a.stack[len(a.stack)-1]
+	if curr.text != "" {
+		text = curr.text + sep + text
+	}
+	curr.text = text
+}
+
+// addCData records a CDATA section of the element being analyzed.
+func (a *Analyzer) addCData() {
+	a.stack[len(a.stack)-1].cdata = true
+}
+
+// endElement ends the analysis of an element, and of the document after the root element.
+func (a *Analyzer) endElement() {
+	n := len(a.stack)
+	a.end(a.stack[n-1])
+	a.stack = a.stack[:n-1]
+	if n == 2 {
+		doc := a.stack[0]
+		a.end(doc)
+		doc.counts = make(map[string]int)
+	}
+}
+
+// end updates the path information of an analyzed element.
+func (a *Analyzer) end(e *analyzed) {
+	info := e.info
+	if info == &a.root {
+		// the root count is the number of documents
+		info.Count++
+	}
+	for _, child := range info.children {
+		if child.Attribute {
+			continue
+		}
+		if n := e.counts[child.Name]; n > 0 {
+			child.present++
+			if n > 1 {
+				child.Repeated = true
+			}
+		}
+	}
+	if info == &a.root {
+		return
+	}
+	text := e.text
+	if text != "" {
+		info.addType(text)
+	}
+	if e.attrs {
+		info.Attributes = true
+	}
+	if e.cdata {
+		info.CData = true
+	}
+	if e.elems {
+		info.Elements = true
+		if text != "" {
+			info.Mixed = true
+		}
+	}
+	if e.attrs || e.elems || e.cdata || (text != "" && a.conv.TextObject) {
+		info.objects++
+	} else if text == "" {
+		info.Empty = true
+	}
+}
+
+// info returns the information of a path, creating it if needed.
+func (a *Analyzer) info(parent *PathInfo, path string, name string) *PathInfo {
+	info, ok := a.paths[path]
+	if !ok {
+		info = &PathInfo{Path: path, Name: name, parent: parent}
+		a.paths[path] = info
+		a.order = append(a.order, info)
+		parent.children = append(parent.children, info)
+	}
+	return info
+}
+
+// addType updates the type of a path with a text value.
+func (info *PathInfo) addType(s string) {
+	typ := textType(s)
+	switch {
+	case info.Type == "" || info.Type == typ:
+		info.Type = typ
+	case (info.Type == CastInt && typ == CastFloat) || (info.Type == CastFloat && typ == CastInt):
+		info.Type = CastFloat
+	case isNumberType(info.Type) && isNumberType(typ):
+		// other numbers mixing unsigned integers beyond int64 range are kept exactly
+		info.Type = CastNumber
+	default:
+		info.Type = CastString
+	}
+}
+
+// textType returns the cast type of a text, using the Cast heuristic.
+// Numbers with leading zeros, like "007", and special float values, like "NaN", are strings.
+func textType(s string) string {
+	switch castValue(s).(type) {
+	case bool:
+		return CastBool
+	case int64:
+		if decimalRegexp.MatchString(s) && !hasLeadingZero(s) {
+			return CastInt
+		}
+	case uint64:
+		if decimalRegexp.MatchString(s) && !hasLeadingZero(s) {
+			return CastUint
+		}
+	case float64:
+		if numberRegexp.MatchString(s) && !hasLeadingZero(s) {
+			return CastFloat
+		}
+	}
+	return CastString
+}
+
+// isNumberType returns true for numeric cast types.
+func isNumberType(typ string) bool {
+	return typ == CastInt || typ == CastUint || typ == CastFloat || typ == CastNumber
+}
+
+func hasLeadingZero(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	return len(s) > 1 && s[0] == '0' && s[1] >= '0' && s[1] <= '9'
+}
+
+// Paths returns the information of all elements and attributes paths, in document order.
+func (a *Analyzer) Paths() []*PathInfo {
+	for _, info := range a.order {
+		info.Optional = info.present < info.parent.Count
+	}
+	return a.order
+}
+
+// Config returns the decoder configuration for the analyzed documents:
+// repeated elements are forced to lists, and texts and attributes are casted to their type.
+func (a *Analyzer) Config() *Config {
+	c := &Config{}
+	for _, info := range a.Paths() {
+		if info.Repeated {
+			c.ForceList = append(c.ForceList, info.Path)
+		}
+		if info.Type != "" {
+			c.CastRules = append(c.CastRules, info.Path+"="+info.Type)
+		}
+	}
+	return c
+}
+
+// JSONSchema returns a JSON Schema of the JSON values decoded from documents like the analyzed ones,
+// using the analyzed decoder convention and the Config configuration.
+func (a *Analyzer) JSONSchema() *OrderedMap {
+	a.Paths()
+	s := a.objectSchema(&a.root)
+	res := NewOrderedMap()
+	res.Set("$schema", JSONSchemaVersion)
+	for _, k := range s.Keys() {
+		v, _ := s.Get(k)
+		res.Set(k, v)
+	}
+	return res
+}
+
+// elementSchema returns the schema of an element, being a list if it is repeated.
+func (a *Analyzer) elementSchema(info *PathInfo) any {
+	s := a.valueSchema(info)
+	if info.Repeated {
+		array := schemaOf("type", "array")
+		array.Set("items", s)
+		return array
+	}
+	return s
+}
+
+// valueSchema returns the schema of a single element, being a scalar, an object or both.
+func (a *Analyzer) valueSchema(info *PathInfo) any {
+	var s any
+	switch {
+	case info.objects == 0:
+		s = scalarSchema(info.Type, info.Empty || info.Type == "")
+	case info.objects == info.Count:
+		s = a.objectSchema(info)
+	default:
+		s = schemaOf("anyOf", []any{scalarSchema(info.Type, true), a.objectSchema(info)})
+	}
+	return s
+}
+
+// objectSchema returns the schema of an element decoded as an object.
+func (a *Analyzer) objectSchema(info *PathInfo) *OrderedMap {
+	s := schemaOf("type", "object")
+	props := NewOrderedMap()
+	attrs := props
+	var required []any
+	if a.conv.AttrKey != "" && info.Attributes {
+		attrs = NewOrderedMap()
+		group := schemaOf("type", "object")
+		group.Set("properties", attrs)
+		props.Set(a.conv.AttrKey, group)
+	}
+	for _, child := range info.children {
+		if child.Attribute {
+			attrs.Set(a.conv.AttrPrefix+child.Name, scalarSchema(child.Type, false))
+			if !child.Optional && attrs == props {
+				required = append(required, a.conv.AttrPrefix+child.Name)
+			}
+		}
+	}
+	mixed := a.mixed && info.Mixed
+	// the text of mixed content is in "#mixed" segments
+	if info.Type != "" && !mixed {
+		props.Set(a.conv.TextKey, scalarSchema(info.Type, false))
+	}
+	if info.CData {
+		props.Set(CDataKey, schemaOf("type", "string"))
+	}
+	var segments *OrderedMap
+	if mixed {
+		segments = NewOrderedMap()
+		props.Set(MixedKey, mixedSchema(segments))
+	}
+	for _, child := range info.children {
+		if !child.Attribute {
+			props.Set(child.Name, a.elementSchema(child))
+			if mixed {
+				segments.Set(child.Name, a.valueSchema(child))
+			}
+			if !child.Optional && !mixed {
+				required = append(required, child.Name)
+			}
+		}
+	}
+	s.Set("properties", props)
+	if len(required) > 0 {
+		s.Set("required", required)
+	}
+	return s
+}
+
+// scalarSchema returns the schema of a text or attribute value.
+func scalarSchema(typ string, nullable bool) *OrderedMap {
+	var t string
+	switch typ {
+	case CastBool:
+		t = "boolean"
+	case CastInt, CastUint:
+		t = "integer"
+	case CastFloat, CastNumber:
+		t = "number"
+	case CastString:
+		t = "string"
+	default:
+		return schemaOf("type", "null")
+	}
+	if nullable {
+		return schemaOf("type", []any{t, "null"})
+	}
+	return schemaOf("type", t)
+}
+
+func schemaOf(key string, value any) *OrderedMap {
+	s := NewOrderedMap()
+	s.Set(key, value)
+	return s
+}
@@ -0,0 +1,101 @@
+package xqml
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_Analyzer(t *testing.T) {
+	a := NewAnalyzer()
+	for _, src := range []string{
+		`<r a="1"><e id="007">1</e><e id="2">x</e><n>1.5</n><m/><b>true</b></r>`,
+		`<r a="2"><e>2</e><n>2</n><m>1</m></r>`,
+	} {
+		err := a.Analyze(NewDecoder(strings.NewReader(src)))
+		if err != nil {
+			t.Errorf("ERROR: %v", err)
+		}
+	}
+	testAnalyzer(t, a.Paths(), `[{"path":"r","name":"r","count":2,"attributes":true,"elements":true},{"path":"r.@a","name":"a","attribute":true,"count":2,"type":"int"},{"path":"r.e","name":"e","count":3,"repeated":true,"attributes":true,"type":"string"},{"path":"r.e.@id","name":"id","attribute":true,"count":2,"optional":true,"type":"string"},{"path":"r.n","name":"n","count":2,"type":"float"},{"path":"r.m","name":"m","count":2,"empty":true,"type":"int"},{"path":"r.b","name":"b","count":1,"optional":true,"type":"bool"}]`)
+	testAnalyzer(t, a.Config(), `{"forceList":["r.e"],"castRules":["r.@a=int","r.e=string","r.e.@id=string","r.n=float","r.m=int","r.b=bool"]}`)
+	testAnalyzer(t, a.JSONSchema(), `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{"r":{"type":"object","properties":{"@a":{"type":"integer"},"e":{"type":"array","items":{"anyOf":[{"type":["string","null"]},{"type":"object","properties":{"@id":{"type":"string"},"#text":{"type":"string"}}}]}},"n":{"type":"number"},"m":{"type":["integer","null"]},"b":{"type":"boolean"}},"required":["@a","e","n","m"]}},"required":["r"]}`)
+	// apply config
+	x := NewDecoder(strings.NewReader(`<r a="3"><e>1</e><n>3</n><m>01</m></r>`))
+	a.Config().Apply(x)
+	var v any
+	err := x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	testAnalyzer(t, v, `{"r":{"@a":3,"e":["1"],"m":1,"n":3}}`)
+}
+
+func Test_AnalyzerUint(t *testing.T) {
+	a := NewAnalyzer()
+	err := a.Analyze(NewDecoder(strings.NewReader(`<r><u>18446744073709551615</u><n>-1</n><n>18446744073709551615</n><f>1.5</f><f>18446744073709551615</f></r>`)))
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	testAnalyzer(t, a.Config(), `{"forceList":["r.n","r.f"],"castRules":["r.u=uint","r.n=number","r.f=number"]}`)
+	// apply config
+	x := NewDecoder(strings.NewReader(`<r><u>18446744073709551615</u><n>-1</n><n>18446744073709551615</n><f>1.5</f><f>18446744073709551615</f></r>`))
+	a.Config().Apply(x)
+	var v any
+	err = x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	testAnalyzer(t, v, `{"r":{"f":[1.5,18446744073709551615],"n":[-1,18446744073709551615],"u":18446744073709551615}}`)
+}
+
+func Test_AnalyzerConvention(t *testing.T) {
+	a := NewAnalyzer()
+	x := NewDecoder(strings.NewReader(`<r><e id="1">a</e><e/></r><r><e>b<f/>c</e></r>`))
+	x.SetConvention(Abdera)
+	x.Partials = true
+	x.Mixed = true
+	err := a.Analyze(x)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	testAnalyzer(t, a.JSONSchema(), `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{"r":{"type":"object","properties":{"e":{"type":"array","items":{"anyOf":[{"type":["string","null"]},{"type":"object","properties":{"attributes":{"type":"object","properties":{"id":{"type":"integer"}}},"#mixed":{"type":"array","items":{"anyOf":[{"type":"string"},{"type":"object","properties":{"f":{"type":"null"}},"minProperties":1,"maxProperties":1}]}},"f":{"type":"null"}}}]}}},"required":["e"]}},"required":["r"]}`)
+	// non-partial documents
+	err = NewAnalyzer().Analyze(NewDecoder(strings.NewReader(`<r/><r/>`)))
+	if err == nil {
+		t.Errorf("ERROR: expected error")
+	}
+}
+
+func Test_AnalyzerOptions(t *testing.T) {
+	// whitespace and CDATA sections
+	a := NewAnalyzer()
+	x := NewDecoder(strings.NewReader(`<r><p> 1 </p><p>  </p><c><![CDATA[<b>]]></c></r>`))
+	x.Whitespace = WhitespacePreserve
+	x.CData = true
+	err := a.Analyze(x)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	testAnalyzer(t, a.Paths(), `[{"path":"r","name":"r","count":1,"elements":true},{"path":"r.p","name":"p","count":2,"repeated":true,"type":"string"},{"path":"r.c","name":"c","count":1,"cdata":true}]`)
+	testAnalyzer(t, a.JSONSchema(), `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{"r":{"type":"object","properties":{"p":{"type":"array","items":{"type":"string"}},"c":{"type":"object","properties":{"#cdata":{"type":"string"}}}},"required":["p","c"]}},"required":["r"]}`)
+	// limits and errors positions
+	x = NewDecoder(strings.NewReader("<r>\n<e><f/></e></r>"))
+	x.MaxDepth = 2
+	err = NewAnalyzer().Analyze(x)
+	var derr *DecodeError
+	if !errors.As(err, &derr) || !errors.Is(err, ErrMaxDepth) || err.Error() != "2:4: r.e.f: maximum depth exceeded (2)" {
+		t.Errorf("ERROR: received %v", err)
+	}
+}
+
+func testAnalyzer(t *testing.T, value any, rjson string) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if string(b) != rjson {
+		t.Errorf("ERROR: received %s", b)
+	}
+}
@@ -0,0 +1,235 @@
+package xqml
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Cast types supported by CastRules.
+const (
+	CastString  = "string"
+	CastInt     = "int"
+	CastUint    = "uint"
+	CastFloat   = "float"
+	CastDecimal = "decimal"
+	CastNumber  = "number"
+	CastBool    = "bool"
+	CastAuto    = "auto"
+)
+
+// Caster allows to cast text and attributes values, knowing their path.
+// Paths use the dotted notation, like "r.x" for elements and "r.x.@a" for attributes.
+type Caster interface {
+	Cast(path string, value string) (any, error)
+}
+
+// CasterFunc is an adapter to use a function as a Caster.
+type CasterFunc func(path string, value string) (any, error)
+
+// Cast calls f(path, value).
+func (f CasterFunc) Cast(path string, value string) (any, error) {
+	return f(path, value)
+}
+
+// pathRule is a rule for paths matching a "*" wildcard pattern.
+type pathRule struct {
+	pattern *regexp.Regexp
+	typ     string
+}
+
+// pathRules are rules like cast rules, by exact path or name, then by wildcard pattern in order.
+type pathRules struct {
+	paths    map[string]string
+	patterns []*pathRule
+}
+
+var (
+	decimalRegexp = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)$`)
+	numberRegexp  = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+	// jsonNumberRegexp matches numbers valid in JSON, see json.Number
+	jsonNumberRegexp = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+)
+
+// newCastRules parses rules like "r.x=int", also supporting multiple comma separated rules.
+func newCastRules(rules []string) (*pathRules, error) {
+	return newPathRules(rules, "cast", CastString, CastInt, CastUint, CastFloat, CastDecimal, CastNumber, CastBool, CastAuto)
+}
+
+// newPathRules parses rules like "r.x=type", types being one of types, also supporting multiple comma separated rules.
+func newPathRules(rules []string, kind string, types ...string) (*pathRules, error) {
+	res := &pathRules{paths: make(map[string]string)}
+	for _, a := range rules {
+		for _, b := range strings.Split(a, ",") {
+			path, typ, ok := strings.Cut(b, "=")
+			if !ok || path == "" {
+				return nil, fmt.Errorf("invalid %s rule '%s', must be path=type", kind, b)
+			}
+			valid := false
+			for _, t := range types {
+				valid = valid || t == typ
+			}
+			if !valid {
+				return nil, fmt.Errorf("invalid %s rule '%s', unknown type '%s'", kind, b, typ)
+			}
+			if strings.Contains(path, "*") {
+				pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(path), `\*`, ".*") + "$"
+				res.patterns = append(res.patterns, &pathRule{regexp.MustCompile(pattern), typ})
+			} else {
+				res.paths[path] = typ
+			}
+		}
+	}
+	return res, nil
+}
+
+// find returns the cast type of a path or name, or "" if no rule matches.
+func (r *pathRules) find(path string, name string) string {
+	if r == nil {
+		return ""
+	}
+	if typ, ok := r.paths[path]; ok {
+		return typ
+	}
+	if typ, ok := r.paths[name]; ok {
+		return typ
+	}
+	for _, rule := range r.patterns {
+		if rule.pattern.MatchString(path) {
+			return rule.typ
+		}
+	}
+	return ""
+}
+
+// castText casts the text of the element at path, schema being the cast type from Schema or "".
+func (x *Decoder) castText(path string, name string, schema string, s string) (any, error) {
+	if x.raw {
+		return s, nil
+	}
+	if x.Caster != nil {
+		return x.Caster.Cast(path, s)
+	}
+	if typ := x.castRules.find(path, name); typ != "" {
+		return x.castType(path, s, typ)
+	}
+	if schema != "" {
+		return x.castSchema(path, s, schema), nil
+	}
+	if x.Cast {
+		return x.castAuto(s), nil
+	}
+	return s, nil
+}
+
+// castAttr casts the attribute value at path, using Cast heuristic only if CastAttrs is true.
+func (x *Decoder) castAttr(path string, name string, schema string, s string) (any, error) {
+	if x.raw {
+		return s, nil
+	}
+	if x.Caster != nil {
+		return x.Caster.Cast(path, s)
+	}
+	if typ := x.castRules.find(path, name); typ != "" {
+		return x.castType(path, s, typ)
+	}
+	if schema != "" {
+		return x.castSchema(path, s, schema), nil
+	}
+	if x.Cast && x.CastAttrs {
+		return x.castAuto(s), nil
+	}
+	return s, nil
+}
+
+// castAuto casts a value with the Cast heuristic, returning json.Number for numbers if UseNumber is true.
+func (x *Decoder) castAuto(s string) any {
+	if x.UseNumber {
+		return castNumber(s)
+	}
+	return castValue(s)
+}
+
+// castNumber casts a value like castValue, but returns json.Number for numbers, keeping their exact text.
+// Numbers which are not valid JSON numbers, like "01234" or "+1", are kept as strings.
+func castNumber(s string) any {
+	if jsonNumberRegexp.MatchString(s) {
+		return json.Number(s)
+	}
+	if b, isBool := castValue(s).(bool); isBool {
+		return b
+	}
+	return s
+}
+
+// castType casts a value to a cast type.
+func (x *Decoder) castType(path string, s string, typ string) (any, error) {
+	var value any
+	var err error
+	switch typ {
+	case CastString:
+		return s, nil
+	case CastAuto:
+		return x.castAuto(s), nil
+	case CastInt:
+		value, err = strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	case CastUint:
+		value, err = strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	case CastFloat:
+		var f float64
+		f, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+		// infinities and NaN can't be encoded to JSON
+		if err == nil && (math.IsInf(f, 0) || math.IsNaN(f)) {
+			err = strconv.ErrSyntax
+		}
+		value = f
+	case CastBool:
+		value, err = strconv.ParseBool(strings.TrimSpace(s))
+	case CastDecimal:
+		value, err = newNumber(s, decimalRegexp)
+	case CastNumber:
+		value, err = newNumber(s, numberRegexp)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value '%s' at '%s'", typ, s, path)
+	}
+	return value, nil
+}
+
+// castSchema casts a value to its Schema cast type, keeping it unchanged if it is invalid.
+func (x *Decoder) castSchema(path string, s string, typ string) any {
+	value, err := x.castType(path, s, typ)
+	if err != nil {
+		return s
+	}
+	return value
+}
+
+// newNumber returns a json.Number keeping the digits of s, removing the "+" sign and leading zeros to be a valid JSON number.
+func newNumber(s string, valid *regexp.Regexp) (json.Number, error) {
+	s = strings.TrimSpace(s)
+	if !valid.MatchString(s) {
+		return "", fmt.Errorf("invalid number")
+	}
+	sign := ""
+	if s[0] == '-' || s[0] == '+' {
+		if s[0] == '-' {
+			sign = "-"
+		}
+		s = s[1:]
+	}
+	s = strings.TrimLeft(s, "0")
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		s = "0" + s
+	}
+	if strings.HasSuffix(s, ".") {
+		s = s + "0"
+	}
+	if i := strings.IndexAny(s, "eE"); i > 0 && s[i-1] == '.' {
+		s = s[:i] + "0" + s[i:]
+	}
+	return json.Number(sign + s), nil
+}
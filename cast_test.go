@@ -0,0 +1,91 @@
+package xqml
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_CastRules(t *testing.T) {
+	src := `<r id="007"><id>01234</id><price>0012.50</price><version>1.10</version><enabled>1</enabled><x><enabled>false</enabled></x><n>5</n></r>`
+	testCast(t, src, []string{"r.id=string", "price=decimal,version=string", "*.enabled=bool"}, false, nil, `{"r":{"@id":"007","enabled":true,"id":"01234","n":5,"price":12.50,"version":"1.10","x":{"enabled":false}}}`)
+	// attributes
+	testCast(t, src, []string{"r.@id=int"}, false, nil, `{"r":{"@id":7,"enabled":1,"id":1234,"n":5,"price":12.5,"version":1.1,"x":{"enabled":false}}}`)
+	testCast(t, `<r a="1" b="true">1</r>`, nil, true, nil, `{"r":{"#text":1,"@a":1,"@b":true}}`)
+	// numbers
+	testCast(t, `<r><a>-.5</a><b>+1.</b><c>1e3</c><d>00</d></r>`, []string{"*=number"}, false, nil, `{"r":{"a":-0.5,"b":1.0,"c":1e3,"d":0}}`)
+	// caster
+	caster := CasterFunc(func(path string, value string) (any, error) {
+		return path + "=" + value, nil
+	})
+	testCast(t, `<r a="1"><e>2</e></r>`, []string{"r.e=int"}, false, caster, `{"r":{"@a":"r.@a=1","e":"r.e=2"}}`)
+}
+
+func Test_CastErrors(t *testing.T) {
+	for _, rules := range [][]string{{"r.e"}, {"r.e=date"}, {"r.e=int"}, {"r.e=decimal"}, {"r.e=bool"}} {
+		x := NewDecoder(strings.NewReader(`<r><e>1.5x</e></r>`))
+		x.CastRules = rules
+		var v any
+		if err := x.Decode(&v); err == nil {
+			t.Errorf("ERROR: expected error for %v", rules)
+		}
+	}
+	x := NewDecoder(strings.NewReader(`<r><e>1</e></r>`))
+	x.Caster = CasterFunc(func(path string, value string) (any, error) {
+		return nil, fmt.Errorf("error")
+	})
+	var v any
+	if err := x.Decode(&v); err == nil {
+		t.Errorf("ERROR: expected error for caster")
+	}
+}
+
+func testCast(t *testing.T, src string, rules []string, castAttrs bool, caster Caster, rjson string) {
+	t.Logf("")
+	t.Logf("xml => json: %s => %s\n", src, rjson)
+	x := NewDecoder(strings.NewReader(src))
+	x.CastRules = rules
+	x.CastAttrs = castAttrs
+	x.Caster = caster
+	var v any
+	err := x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res := Stringify(v); res != rjson {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
+
+func Test_UseNumber(t *testing.T) {
+	src := `<r><a>1.10</a><b>12345678901234567890.123</b><c>1e3</c><d>01234</d><e>-0</e><f>true</f><g>+1</g></r>`
+	rjson := `{"r":{"a":1.10,"b":12345678901234567890.123,"c":1e3,"d":"01234","e":-0,"f":true,"g":"+1"}}`
+	x := NewDecoder(strings.NewReader(src))
+	x.UseNumber = true
+	var v any
+	err := x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	res := Stringify(v)
+	if res != rjson {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+	// json => xml keeps exact digits
+	for _, toJson := range []func([]byte) (any, error){
+		func(b []byte) (any, error) { return ToJsonNumber(b) },
+		func(b []byte) (any, error) { return ToOrderedJson(b) },
+	} {
+		j, err := toJson([]byte(res))
+		if err != nil {
+			t.Errorf("ERROR: %v", err)
+		}
+		rxml, err := encode(j)
+		if err != nil {
+			t.Errorf("ERROR: %v", err)
+		}
+		if rxml != src {
+			t.Errorf("ERROR: received %s\n", rxml)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package xqml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_CData(t *testing.T) {
+	testCData(t, `<r><s><![CDATA[ a < b ]]></s></r>`, `{"r":{"s":{"#cdata":" a \u003c b "}}}`, `<r><s><![CDATA[ a < b ]]></s></r>`)
+	testCData(t, `<r><s>x <![CDATA[1]]><![CDATA[2]]></s></r>`, `{"r":{"s":{"#cdata":"12","#text":"x"}}}`, `<r><s>x<![CDATA[12]]></s></r>`)
+	testCData(t, `<r a="1"><![CDATA[]]]]><![CDATA[>]]></r>`, `{"r":{"#cdata":"]]\u003e","@a":"1"}}`, `<r a="1"><![CDATA[]]]]><![CDATA[>]]></r>`)
+	// converted input
+	testCData(t, "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><r><a>\xe9\xe9</a><s><![CDATA[x<y]]></s></r>", `{"r":{"a":"éé","s":{"#cdata":"x\u003cy"}}}`, `<r><a>éé</a><s><![CDATA[x<y]]></s></r>`)
+	// not a CDATA section
+	testCData(t, `<r><s>&lt;![CDATA[1]]&gt;</s><!--<![CDATA[--></r>`, `{"r":{"s":"\u003c![CDATA[1]]\u003e"}}`, `<r><s>&lt;![CDATA[1]]&gt;</s></r>`)
+	// always CDATA
+	var b bytes.Buffer
+	x := NewEncoder(&b)
+	x.CDataPaths = []string{"r.s", "t"}
+	err := x.Encode(map[string]any{"r": map[string]any{"s": []any{"<a>", 1}, "t": map[string]any{"@x": 1, "#text": "&"}, "u": "<"}})
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res := b.String(); res != `<r><s><![CDATA[<a>]]></s><s><![CDATA[1]]></s><t x="1"><![CDATA[&]]></t><u>&lt;</u></r>` {
+		t.Errorf("ERROR: received %s", res)
+	}
+}
+
+func testCData(t *testing.T, src string, rjson string, rxml string) {
+	t.Logf("")
+	t.Logf("xml => json: %s => %s\n", src, rjson)
+	x := NewDecoder(strings.NewReader(src))
+	x.CData = true
+	var v any
+	err := x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	res := Stringify(v)
+	if res != rjson {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+	t.Logf("json => xml: %s => %s\n", rjson, rxml)
+	res, err = encode(v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res != rxml {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
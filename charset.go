@@ -0,0 +1,63 @@
+package xqml
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/transform"
+)
+
+// CharsetReader returns a reader converting an input encoded with a charset to UTF-8, see Decoder.CharsetReader.
+type CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+
+// NewCharsetReader returns a reader converting an input encoded with a charset to UTF-8.
+// Charsets are the IANA names and aliases, like "ISO-8859-1", "windows-1252", "Shift_JIS", "EUC-JP", "GB18030", "Big5" or "EUC-KR",
+// and the WHATWG labels, like "latin1" or "sjis". It is the default Decoder.CharsetReader.
+func NewCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := charsetEncoding(charset)
+	if err != nil {
+		return nil, err
+	}
+	return transform.NewReader(input, enc.NewDecoder()), nil
+}
+
+// charsetEncoding returns the encoding of a charset, by its IANA name or its WHATWG label.
+func charsetEncoding(charset string) (encoding.Encoding, error) {
+	if enc, err := ianaindex.IANA.Encoding(charset); err == nil && enc != nil {
+		return enc, nil
+	}
+	if enc, err := htmlindex.Get(charset); err == nil {
+		return enc, nil
+	}
+	return nil, fmt.Errorf("unsupported charset '%s'", charset)
+}
+
+// isUTF8 returns true if a charset is UTF-8, an empty charset being UTF-8.
+func isUTF8(charset string) bool {
+	return charset == "" || strings.EqualFold(charset, "utf-8") || strings.EqualFold(charset, "utf8")
+}
+
+// encodable returns true if a character can be written with the output encoding.
+func (x *Encoder) encodable(r rune) bool {
+	if r < utf8.RuneSelf || x.charset == nil {
+		return true
+	}
+	_, err := x.charset.String(string(r))
+	return err == nil
+}
+
+// checkEncodable returns an error if a text, which can't contain character references, has characters
+// the output encoding does not support.
+func (x *Encoder) checkEncodable(kind string, text string) error {
+	for _, r := range text {
+		if !x.encodable(r) {
+			return fmt.Errorf("invalid %s '%s', character '%c' is not supported by encoding '%s'", kind, text, r, x.Encoding)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package xqml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_Charset(t *testing.T) {
+	testCharset(t, "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><r>caf\xe9</r>", nil, `{"r":"café"}`)
+	testCharset(t, "<?xml version=\"1.0\" encoding=\"windows-1252\"?><r a=\"\x80\">\x93x\x94</r>", nil, `{"r":{"#text":"“x”","@a":"€"}}`)
+	testCharset(t, "<?xml version=\"1.0\" encoding=\"latin1\"?><r>\xe9</r>", nil, `{"r":"é"}`)
+	testCharset(t, "<?xml version=\"1.0\" encoding=\"Shift_JIS\"?><r>\x93\xfa\x96\x7b</r>", nil, `{"r":"日本"}`)
+	testCharset(t, "<?xml version=\"1.0\" encoding=\"EUC-KR\"?><r>\xc7\xd1</r>", nil, `{"r":"한"}`)
+	testCharset(t, "<?xml version=\"1.0\" encoding=\"GB18030\"?><r>\xd6\xd0</r>", nil, `{"r":"中"}`)
+	testCharset(t, "<?xml version=\"1.0\" encoding=\"Big5\"?><r>\xa4\xa4</r>", nil, `{"r":"中"}`)
+	testCharset(t, "<?xml version=\"1.0\" encoding=\"x-unknown\"?><r/>", nil, `1:1: xml: opening charset "x-unknown": unsupported charset 'x-unknown'`)
+	// custom charset
+	upper := func(charset string, input io.Reader) (io.Reader, error) {
+		if charset != "x-upper" {
+			return NewCharsetReader(charset, input)
+		}
+		b, err := io.ReadAll(input)
+		return bytes.NewReader(bytes.ToUpper(b)), err
+	}
+	testCharset(t, "<?xml version=\"1.0\" encoding=\"x-upper\"?><r>a</r>", upper, `{"R":"A"}`)
+	testCharset(t, "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><r>\xe9</r>", upper, `{"r":"é"}`)
+	// encoder
+	testEncoding(t, "ISO-8859-1", map[string]any{"r": "café €"}, "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><r>caf\xe9 &#8364;</r>", `{"r":"café €"}`)
+	testEncoding(t, "Shift_JIS", map[string]any{"?xml": `version="1.0"`, "r": map[string]any{"@a": "日本"}}, "<?xml version=\"1.0\" encoding=\"Shift_JIS\"?><r a=\"\x93\xfa\x96\x7b\"></r>", `{"r":{"@a":"日本"}}`)
+	testEncoding(t, "UTF-8", map[string]any{"r": "é"}, "<r>é</r>", `{"r":"é"}`)
+	// characters not supported by the encoding
+	testEncoding(t, "ISO-8859-1", map[string]any{"r": map[string]any{"#cdata": "€ é<€€x"}}, "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><r>&#8364;<![CDATA[ \xe9<]]>&#8364;&#8364;<![CDATA[x]]></r>", `{"r":"€ é\u003c €€ x"}`)
+	testEncoding(t, "ISO-8859-1", map[string]any{"r": map[string]any{"#cdata": ""}}, "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><r><![CDATA[]]></r>", `{"r":null}`)
+	testEncoding(t, "ISO-8859-1", map[string]any{"r": map[string]any{"#comment": "€"}}, "invalid comment '€', character '€' is not supported by encoding 'ISO-8859-1'", "")
+	testEncoding(t, "ISO-8859-1", map[string]any{"r": map[string]any{"?pi": "é€"}}, "invalid processing instruction 'é€', character '€' is not supported by encoding 'ISO-8859-1'", "")
+	testEncoding(t, "x-unknown", map[string]any{"r": "é"}, "unsupported charset 'x-unknown'", "")
+}
+
+func testCharset(t *testing.T, src string, charsetReader CharsetReader, rjson string) {
+	t.Logf("")
+	t.Logf("xml => json: %q => %s\n", src, rjson)
+	x := NewDecoder(strings.NewReader(src))
+	if charsetReader != nil {
+		x.CharsetReader = charsetReader
+	}
+	var v any
+	err := x.Decode(&v)
+	res := ""
+	if err != nil {
+		res = err.Error()
+	} else {
+		res = Stringify(v)
+	}
+	if res != rjson {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
+
+func testEncoding(t *testing.T, encoding string, value any, rxml string, rjson string) {
+	t.Logf("")
+	t.Logf("json => xml: %s => %q\n", Stringify(value), rxml)
+	var b bytes.Buffer
+	x := NewEncoder(&b)
+	x.Encoding = encoding
+	err := x.Encode(value)
+	res := b.String()
+	if err != nil {
+		res = fmt.Sprintf("%v", err)
+	}
+	if res != rxml {
+		t.Errorf("ERROR: received %q\n", res)
+	}
+	// round trip
+	if err == nil {
+		testCharset(t, res, nil, rjson)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/momiji/xqml"
+)
+
+// analyze prints the structure of sample XML documents, with the inferred decoder configuration and JSON Schema.
+func analyze(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	o := &options{}
+	fs := flag.NewFlagSet("xqml analyze", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: xqml analyze [flags] [file...]\n\n")
+		fmt.Fprintf(stderr, "Prints the paths found in sample XML documents, the inferred -force-list and -cast-rule flags, and a JSON Schema.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	addDecoderFlags(fs, o)
+	config := fs.Bool("config", false, "print only the decoder configuration")
+	schema := fs.Bool("schema", false, "print only the JSON Schema")
+	fs.StringVar(&o.indent, "indent", "", "output indentation")
+	err := fs.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOk
+		}
+		return exitUsage
+	}
+	if _, ok := conventions[o.convention]; o.convention != "" && !ok {
+		fmt.Fprintf(stderr, "xqml: invalid -convention '%s'\n", o.convention)
+		return exitUsage
+	}
+	a := xqml.NewAnalyzer()
+	res := openFiles(fs.Args(), stdin, stderr, func(name string, reader io.Reader) error {
+		d, err := o.newDecoder(reader)
+		if err != nil {
+			return err
+		}
+		return a.Analyze(d)
+	})
+	if res != exitOk {
+		return res
+	}
+	var v any
+	switch {
+	case *config:
+		v = a.Config()
+	case *schema:
+		v = a.JSONSchema()
+	default:
+		m := xqml.NewOrderedMap()
+		m.Set("paths", a.Paths())
+		m.Set("config", a.Config())
+		m.Set("schema", a.JSONSchema())
+		v = m
+	}
+	err = o.newJsonEncoder(stdout).Encode(v)
+	if err != nil {
+		fmt.Fprintf(stderr, "xqml: %v\n", err)
+		return exitError
+	}
+	return exitOk
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/momiji/xqml"
+)
+
+var conventions = map[string]xqml.Convention{
+	"xqml":       xqml.Xqml,
+	"xmltodict":  xqml.XmlToDict,
+	"badgerfish": xqml.BadgerFish,
+	"parker":     xqml.Parker,
+	"gdata":      xqml.GData,
+	"abdera":     xqml.Abdera,
+}
+
+// options are the decoder and encoder options set from flags.
+type options struct {
+	// conversion
+	to string
+	// decoder
+	convention string
+	attrPrefix stringFlag
+	attrKey    stringFlag
+	textKey    stringFlag
+	textObject bool
+	nsMap      mapFlag
+	entities   mapFlag
+	noAttrs    bool
+	noNs       bool
+	nsPrefixes bool
+	noNsDecls  bool
+	forceList  listFlag
+	html       bool
+	html5      bool
+	partials   bool
+	noCast     bool
+	useNumber  bool
+	castRules  listFlag
+	castAttrs  bool
+	sep        string
+	whitespace string
+	wsRules    listFlag
+	xmlSpace   bool
+	ordered    bool
+	mixed      bool
+	cdata      bool
+	comments   bool
+	procInsts  bool
+	directives bool
+	itemDepth  int
+	itemPath   listFlag
+	xsd        string
+	schema     *xqml.Schema
+	// limits
+	maxDepth    int
+	maxElements int
+	maxAttrs    int
+	maxText     int
+	maxInput    int64
+	maxEntities int
+	// encoder
+	cdataPaths  listFlag
+	declaration bool
+	version     string
+	standalone  string
+	encoding    string
+	htmlOutput  bool
+	indent      string
+	root        string
+	element     string
+}
+
+// addDecoderFlags adds the Decoder flags to a flag set.
+func addDecoderFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.convention, "convention", "", "attributes and text convention: xqml, xmltodict, badgerfish, parker, gdata or abdera")
+	fs.Var(&o.attrPrefix, "attr-prefix", "prefix of attributes keys (default \"@\")")
+	fs.Var(&o.attrKey, "attr-key", "key of the object holding attributes, empty meaning attributes are keys of the element")
+	fs.Var(&o.textKey, "text-key", "key of text (default \"#text\")")
+	fs.BoolVar(&o.textObject, "text-object", false, "always put text in the text key, even for elements with text only")
+	fs.Var(&o.nsMap, "ns-map", "namespace prefix used instead of a namespace URI, like \"atom=http://www.w3.org/2005/Atom\" (repeatable)")
+	fs.BoolVar(&o.noAttrs, "no-attrs", false, "drop attributes")
+	fs.BoolVar(&o.noNs, "no-ns", false, "drop namespaces")
+	fs.BoolVar(&o.nsPrefixes, "ns-prefixes", false, "use document namespace prefixes instead of namespace URIs")
+	fs.BoolVar(&o.noNsDecls, "no-ns-decls", false, "drop xmlns namespace declarations")
+	fs.Var(&o.forceList, "force-list", "force elements to be lists, like \"r.x\" or \"x\" (repeatable, comma separated)")
+	fs.BoolVar(&o.html, "html", false, "allow HTML content")
+	fs.BoolVar(&o.html5, "html5", false, "parse HTML content with the HTML5 tree building rules")
+	fs.BoolVar(&o.partials, "partials", false, "read multiple XML documents")
+	fs.BoolVar(&o.noCast, "no-cast", false, "do not cast values to boolean/int/float")
+	fs.BoolVar(&o.useNumber, "use-number", false, "keep exact numbers text")
+	fs.Var(&o.castRules, "cast-rule", "cast rule, like \"r.id=string\" (repeatable, comma separated)")
+	fs.BoolVar(&o.castAttrs, "cast-attrs", false, "cast attributes values")
+	fs.StringVar(&o.sep, "sep", " ", "text separator between multiple text parts")
+	fs.StringVar(&o.whitespace, "whitespace", "trim", "whitespace mode of text: trim, preserve or collapse")
+	fs.Var(&o.wsRules, "whitespace-rule", "whitespace rule, like \"r.pre=preserve\" (repeatable, comma separated)")
+	fs.BoolVar(&o.xmlSpace, "xml-space", false, "honor xml:space attributes")
+	fs.BoolVar(&o.ordered, "ordered", false, "keep elements order")
+	fs.BoolVar(&o.mixed, "mixed", false, "keep mixed content in order")
+	fs.BoolVar(&o.cdata, "cdata", false, "keep CDATA sections in \"#cdata\" keys")
+	fs.BoolVar(&o.comments, "comments", false, "keep comments in \"#comment\" keys")
+	fs.BoolVar(&o.procInsts, "procinsts", false, "keep processing instructions in \"?target\" keys, including the XML declaration")
+	fs.BoolVar(&o.directives, "directives", false, "keep directives in \"!name\" keys, like \"!doctype\"")
+	fs.Var(&o.entities, "entity", "entity value, like \"nbsp=\u00a0\" (repeatable)")
+	fs.StringVar(&o.xsd, "xsd", "", "XML Schema file used to force lists, cast values and add default attributes")
+	fs.IntVar(&o.maxDepth, "max-depth", 0, "maximum depth of elements, 0 meaning no limit")
+	fs.IntVar(&o.maxElements, "max-elements", 0, "maximum number of elements of each document, 0 meaning no limit")
+	fs.IntVar(&o.maxAttrs, "max-attrs", 0, "maximum number of attributes of each element, 0 meaning no limit")
+	fs.IntVar(&o.maxText, "max-text-bytes", 0, "maximum text size of each element, 0 meaning no limit")
+	fs.Int64Var(&o.maxInput, "max-input-bytes", 0, "maximum input size, 0 meaning no limit")
+	fs.IntVar(&o.maxEntities, "max-entities", 0, "maximum number of entity references, 0 meaning no limit")
+}
+
+// newDecoder returns a decoder set from flags.
+func (o *options) newDecoder(reader io.Reader) (*xqml.Decoder, error) {
+	d := xqml.NewDecoder(reader)
+	if o.convention != "" {
+		c, ok := conventions[o.convention]
+		if !ok {
+			return nil, fmt.Errorf("unknown convention '%s'", o.convention)
+		}
+		d.SetConvention(c)
+	}
+	if o.attrPrefix.set {
+		d.AttrPrefix = o.attrPrefix.value
+	}
+	if o.attrKey.set {
+		d.AttrKey = o.attrKey.value
+	}
+	if o.textKey.set {
+		d.TextKey = o.textKey.value
+	}
+	if o.textObject {
+		d.TextObject = true
+	}
+	d.NsMap = o.uriPrefixes()
+	d.Entities = o.entities
+	if o.noAttrs {
+		d.Attributes = false
+	}
+	d.Namespaces = !o.noNs
+	d.NsPrefixes = o.nsPrefixes
+	d.NsDeclarations = !o.noNsDecls
+	d.ForceList = o.forceList
+	d.Html = o.html
+	d.Html5 = o.html5
+	d.Partials = o.partials
+	d.Cast = !o.noCast
+	d.UseNumber = o.useNumber
+	d.CastRules = o.castRules
+	d.CastAttrs = o.castAttrs
+	d.Sep = o.sep
+	d.Whitespace = o.whitespace
+	d.WhitespaceRules = o.wsRules
+	d.XmlSpace = o.xmlSpace
+	d.Ordered = o.ordered
+	d.Mixed = o.mixed
+	d.CData = o.cdata
+	d.Comments = o.comments
+	d.ProcInsts = o.procInsts
+	d.Directives = o.directives
+	d.ItemDepth = o.itemDepth
+	d.ItemPath = o.itemPath
+	d.MaxDepth = o.maxDepth
+	d.MaxElements = o.maxElements
+	d.MaxAttributes = o.maxAttrs
+	d.MaxTextBytes = o.maxText
+	d.MaxInputBytes = o.maxInput
+	d.MaxEntityExpansions = o.maxEntities
+	if o.xsd != "" {
+		if o.schema == nil {
+			schema, err := xqml.LoadSchemaFile(o.xsd)
+			if err != nil {
+				return nil, err
+			}
+			o.schema = schema
+		}
+		d.Schema = o.schema
+	}
+	return d, nil
+}
+
+// newEncoder returns an encoder set from flags.
+func (o *options) newEncoder(writer io.Writer) (*xqml.Encoder, error) {
+	e := xqml.NewEncoder(writer)
+	if o.convention != "" {
+		c, ok := conventions[o.convention]
+		if !ok {
+			return nil, fmt.Errorf("unknown convention '%s'", o.convention)
+		}
+		e.SetConvention(c)
+	}
+	if o.attrPrefix.set {
+		e.AttrPrefix = o.attrPrefix.value
+	}
+	if o.attrKey.set {
+		e.AttrKey = o.attrKey.value
+	}
+	if o.textKey.set {
+		e.TextKey = o.textKey.value
+	}
+	e.NsMap = o.uriPrefixes()
+	e.Indent = o.indent
+	e.CDataPaths = o.cdataPaths
+	e.Declaration = o.declaration
+	e.Version = o.version
+	e.Standalone = o.standalone
+	e.Encoding = o.encoding
+	e.Html = o.htmlOutput
+	e.Root = o.root
+	e.Element = o.element
+	return e, nil
+}
+
+// uriPrefixes returns the namespace URIs to prefixes map of -ns-map flags, nil if none.
+func (o *options) uriPrefixes() map[string]string {
+	if len(o.nsMap) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(o.nsMap))
+	for prefix, uri := range o.nsMap {
+		m[uri] = prefix
+	}
+	return m
+}
+
+// newJsonEncoder returns a JSON encoder set from flags.
+func (o *options) newJsonEncoder(writer io.Writer) *json.Encoder {
+	j := json.NewEncoder(writer)
+	j.SetEscapeHTML(false)
+	j.SetIndent("", o.indent)
+	return j
+}
+
+// convert converts XML to JSON or JSON to XML.
+func convert(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	o := &options{}
+	fs := flag.NewFlagSet("xqml", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: xqml [flags] [file...]\n\n")
+		fmt.Fprintf(stderr, "Converts XML to JSON and JSON to XML, reading files or standard input.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&o.to, "to", "", "output format: json or xml (default detected from input)")
+	addDecoderFlags(fs, o)
+	fs.IntVar(&o.itemDepth, "item-depth", 0, "stream elements at this depth, 1 being the root element")
+	fs.Var(&o.itemPath, "item-path", "stream elements matching this path, like \"r.x\" (repeatable, comma separated)")
+	fs.StringVar(&o.indent, "indent", "", "output indentation")
+	fs.Var(&o.cdataPaths, "cdata-path", "write text of elements as CDATA sections, like \"r.x\" or \"x\" (repeatable, comma separated)")
+	fs.BoolVar(&o.declaration, "declaration", false, "write an XML declaration")
+	fs.StringVar(&o.version, "version", "1.0", "version of the XML declaration")
+	fs.StringVar(&o.standalone, "standalone", "", "standalone of the XML declaration: yes, no or empty")
+	fs.BoolVar(&o.htmlOutput, "html-output", false, "write HTML void elements, like <br>, without end tag")
+	fs.StringVar(&o.encoding, "encoding", "UTF-8", "output encoding, like \"ISO-8859-1\" or \"Shift_JIS\"")
+	fs.StringVar(&o.root, "root", xqml.DefaultRootTag, "root element name")
+	fs.StringVar(&o.element, "element", xqml.DefaultElementTag, "root list element name")
+	err := fs.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOk
+		}
+		return exitUsage
+	}
+	if o.to != "" && o.to != "json" && o.to != "xml" {
+		fmt.Fprintf(stderr, "xqml: invalid -to '%s', must be json or xml\n", o.to)
+		return exitUsage
+	}
+	if _, ok := conventions[o.convention]; o.convention != "" && !ok {
+		fmt.Fprintf(stderr, "xqml: invalid -convention '%s'\n", o.convention)
+		return exitUsage
+	}
+	return openFiles(fs.Args(), stdin, stderr, func(name string, reader io.Reader) error {
+		br := bufio.NewReader(reader)
+		skipBom(br)
+		to := o.to
+		if to == "" {
+			c, err := peek(br)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			switch c {
+			case '<':
+				to = "json"
+			case '{', '[':
+				to = "xml"
+			default:
+				return fmt.Errorf("cannot detect input format, use -to")
+			}
+		}
+		if to == "json" {
+			return o.xmlToJson(br, stdout)
+		}
+		return o.jsonToXml(br, stdout)
+	})
+}
+
+// utf8Bom is the UTF-8 byte order mark.
+const utf8Bom = "\xef\xbb\xbf"
+
+// skipBom consumes a leading UTF-8 byte order mark.
+func skipBom(br *bufio.Reader) {
+	if b, err := br.Peek(len(utf8Bom)); err == nil && string(b) == utf8Bom {
+		_, _ = br.Discard(len(utf8Bom))
+	}
+}
+
+// peek returns the first character of the input which is not a space, without consuming it.
+func peek(br *bufio.Reader) (byte, error) {
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return c, br.UnreadByte()
+		}
+	}
+}
+
+// xmlToJson converts XML documents to JSON, writing one JSON value per line for multiple documents or items.
+func (o *options) xmlToJson(reader io.Reader, writer io.Writer) error {
+	d, err := o.newDecoder(reader)
+	if err != nil {
+		return err
+	}
+	j := o.newJsonEncoder(writer)
+	for {
+		if o.itemDepth > 0 || len(o.itemPath) > 0 {
+			err = d.Stream(func(path string, value any) error {
+				return j.Encode(value)
+			})
+		} else {
+			var v any
+			err = d.Decode(&v)
+			if err == nil {
+				err = j.Encode(v)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil || !o.partials {
+			return err
+		}
+	}
+}
+
+// jsonToXml converts JSON values to XML, writing one XML document per line.
+func (o *options) jsonToXml(reader io.Reader, writer io.Writer) error {
+	j := json.NewDecoder(reader)
+	j.UseNumber()
+	for {
+		var v any
+		var err error
+		if o.ordered {
+			var raw json.RawMessage
+			err = j.Decode(&raw)
+			if err == nil {
+				v, err = orderedValue(raw)
+			}
+		} else {
+			err = j.Decode(&v)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e, err := o.newEncoder(writer)
+		if err != nil {
+			return err
+		}
+		err = e.Encode(v)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(writer, "\n")
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// orderedValue returns a JSON value with objects read as *xqml.OrderedMap, keeping keys order, and numbers as json.Number.
+func orderedValue(raw json.RawMessage) (any, error) {
+	switch bytes.TrimSpace(raw)[0] {
+	case '{':
+		m := xqml.NewOrderedMap()
+		err := json.Unmarshal(raw, m)
+		return m, err
+	case '[':
+		var items []json.RawMessage
+		err := json.Unmarshal(raw, &items)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]any, len(items))
+		for i, item := range items {
+			list[i], err = orderedValue(item)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	j := json.NewDecoder(bytes.NewReader(raw))
+	j.UseNumber()
+	var v any
+	err := j.Decode(&v)
+	return v, err
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/momiji/xqml"
+)
+
+// gen prints Go structs of sample XML documents, or of an XML Schema if -xsd is set.
+func gen(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	o := &options{}
+	fs := flag.NewFlagSet("xqml gen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: xqml gen [flags] [file...]\n\n")
+		fmt.Fprintf(stderr, "Prints Go structs with xml and xqml tags for sample XML documents, or for the XML Schema set with -xsd.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	addDecoderFlags(fs, o)
+	pkg := fs.String("package", "main", "package name of the generated code")
+	err := fs.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOk
+		}
+		return exitUsage
+	}
+	if _, ok := conventions[o.convention]; o.convention != "" && !ok {
+		fmt.Fprintf(stderr, "xqml: invalid -convention '%s'\n", o.convention)
+		return exitUsage
+	}
+	g := xqml.NewGenerator(*pkg)
+	var code []byte
+	if o.xsd != "" {
+		d, err := o.newDecoder(nil)
+		if err == nil {
+			code, err = g.Schema(d.Schema, d)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "xqml: %v\n", err)
+			return exitError
+		}
+	} else {
+		a := xqml.NewAnalyzer()
+		res := openFiles(fs.Args(), stdin, stderr, func(name string, reader io.Reader) error {
+			d, err := o.newDecoder(reader)
+			if err != nil {
+				return err
+			}
+			return a.Analyze(d)
+		})
+		if res != exitOk {
+			return res
+		}
+		code, err = g.Analyzer(a)
+		if err != nil {
+			fmt.Fprintf(stderr, "xqml: %v\n", err)
+			return exitError
+		}
+	}
+	_, err = stdout.Write(code)
+	if err != nil {
+		fmt.Fprintf(stderr, "xqml: %v\n", err)
+		return exitError
+	}
+	return exitOk
+}
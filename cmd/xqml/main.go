@@ -0,0 +1,144 @@
+// Command xqml converts XML to JSON and JSON to XML.
+//
+// Usage:
+//
+//	xqml [flags] [file...]
+//	xqml query [flags] expr [file...]
+//	xqml analyze [flags] [file...]
+//	xqml validate -xsd file [flags] [file...]
+//	xqml gen [flags] [file...]
+//
+// Files are read in order, "-" or no file meaning standard input.
+// The conversion direction is detected from the first character of each input,
+// unless -to is set. The query command prints the values matching a query
+// in XML documents, like "//e[@id='3']/#text". The analyze command prints the
+// structure of sample XML documents, with the inferred decoder flags and JSON Schema.
+// The validate command validates XML documents against an XML Schema.
+// The gen command prints Go structs of sample XML documents, or of an XML Schema.
+// Run "xqml -h" or "xqml <command> -h" for the list of flags.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/momiji/xqml"
+)
+
+const (
+	exitOk    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command and returns the exit code.
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "query":
+			return query(args[1:], stdin, stdout, stderr)
+		case "analyze":
+			return analyze(args[1:], stdin, stdout, stderr)
+		case "validate":
+			return validate(args[1:], stdin, stdout, stderr)
+		case "gen":
+			return gen(args[1:], stdin, stdout, stderr)
+		}
+	}
+	return convert(args, stdin, stdout, stderr)
+}
+
+// listFlag is a flag which can be repeated, values being accumulated.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// stringFlag is a string flag remembering if it is set, to override defaults even with an empty value.
+type stringFlag struct {
+	value string
+	set   bool
+}
+
+func (s *stringFlag) String() string {
+	return s.value
+}
+
+func (s *stringFlag) Set(value string) error {
+	s.value, s.set = value, true
+	return nil
+}
+
+// mapFlag is a "key=value" flag which can be repeated, values being accumulated.
+type mapFlag map[string]string
+
+func (m *mapFlag) String() string {
+	var pairs []string
+	for key, value := range *m {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m *mapFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("invalid value '%s', must be key=value", value)
+	}
+	if *m == nil {
+		*m = mapFlag{}
+	}
+	(*m)[key] = val
+	return nil
+}
+
+// openFiles calls fn for each file, "-" being stdin. It returns the exit code.
+func openFiles(files []string, stdin io.Reader, stderr io.Writer, fn func(name string, reader io.Reader) error) int {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		var reader io.Reader = stdin
+		var file *os.File
+		if name != "-" {
+			var err error
+			file, err = os.Open(name)
+			if err != nil {
+				fmt.Fprintf(stderr, "xqml: %v\n", err)
+				return exitError
+			}
+			reader = file
+		}
+		err := fn(name, reader)
+		// close each file once read, not when all files are read
+		if file != nil {
+			file.Close()
+		}
+		var derr *xqml.DecodeError
+		if errors.As(err, &derr) {
+			// print position like compilers, as file:line:column
+			fmt.Fprintf(stderr, "xqml: %s:%v\n", name, derr)
+			return exitError
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "xqml: %s: %v\n", name, err)
+			return exitError
+		}
+	}
+	return exitOk
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Run(t *testing.T) {
+	testRun(t, nil, `<r><e>1</e><e>2</e></r>`, `{"r":{"e":[1,2]}}`+"\n", "", exitOk)
+	testRun(t, []string{"-no-cast", "-force-list", "r.f"}, `<r><e>1</e><f>2</f></r>`, `{"r":{"e":"1","f":["2"]}}`+"\n", "", exitOk)
+	testRun(t, []string{"-partials"}, `<r>1</r><r>2</r>`, `{"r":1}`+"\n"+`{"r":2}`+"\n", "", exitOk)
+	testRun(t, []string{"-item-depth", "2"}, `<r><e>1</e><e>2</e></r>`, "1\n2\n", "", exitOk)
+	testRun(t, nil, `{"r":{"e":[1,2.50]}} {"a":"<"}`, "<r><e>1</e><e>2.50</e></r>\n<a>&lt;</a>\n", "", exitOk)
+	testRun(t, []string{"-root", "x"}, `[1]`, "<x><element>1</element></x>\n", "", exitOk)
+	testRun(t, []string{"-to", "xml", "-ordered"}, `{"r":{"b":1,"a":2}}`, "<r><b>1</b><a>2</a></r>\n", "", exitOk)
+	testRun(t, []string{"-to", "xml", "-ordered"}, `[{"b":1,"a":2},"x"]`, "<root><element><b>1</b><a>2</a></element><element>x</element></root>\n", "", exitOk)
+	testRun(t, []string{"-to", "json"}, "\xef\xbb\xbf<r>1</r>", `{"r":1}`+"\n", "", exitOk)
+	testRun(t, []string{}, "\xbb<r>1</r>", "", "xqml: -: cannot detect input format, use -to\n", exitError)
+	testRun(t, []string{"-comments", "-procinsts"}, `<?xml version="1.0"?><r><!--c--></r>`, `{"?xml":"version=\"1.0\"","r":{"#comment":"c"}}`+"\n", "", exitOk)
+	testRun(t, []string{"-declaration"}, `{"r":1}`, "<?xml version=\"1.0\" encoding=\"UTF-8\"?><r>1</r>\n", "", exitOk)
+	testRun(t, []string{"-cdata", "-to", "json"}, `<r><![CDATA[<b>]]></r>`, `{"r":{"#cdata":"<b>"}}`+"\n", "", exitOk)
+	testRun(t, []string{"-cdata-path", "r"}, `{"r":"<b>"}`, "<r><![CDATA[<b>]]></r>\n", "", exitOk)
+	testRun(t, []string{"-whitespace-rule", "pre=preserve", "-to", "json"}, `<r><pre> a </pre><s> a </s></r>`, `{"r":{"pre":" a ","s":"a"}}`+"\n", "", exitOk)
+	testRun(t, []string{"-encoding", "ISO-8859-1"}, `{"r":"é"}`, "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><r>\xe9</r>\n", "", exitOk)
+	testRun(t, []string{"-to", "json"}, "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><r>\xe9</r>", `{"r":"é"}`+"\n", "", exitOk)
+	testRun(t, []string{"-html5", "-to", "json"}, `<ul><li>a<li>b</ul>`, `{"html":{"body":{"ul":{"li":["a","b"]}},"head":null}}`+"\n", "", exitOk)
+	testRun(t, []string{"-html-output"}, `{"p":{"br":null}}`, "<p><br></p>\n", "", exitOk)
+	testRun(t, []string{"-attr-prefix", "-"}, `<r a="1"/>`, `{"r":{"-a":"1"}}`+"\n", "", exitOk)
+	testRun(t, []string{"-attr-prefix", ""}, `{"r":{"a":1}}`, "<r a=\"1\"></r>\n", "", exitOk)
+	testRun(t, []string{"-attr-key", "attrs", "-attr-prefix", ""}, `<r a="1">x</r>`, `{"r":{"#text":"x","attrs":{"a":"1"}}}`+"\n", "", exitOk)
+	testRun(t, []string{"-attr-key", "attrs", "-attr-prefix", ""}, `{"r":{"attrs":{"a":1},"#text":"x"}}`, "<r a=\"1\">x</r>\n", "", exitOk)
+	testRun(t, []string{"-text-key", "$"}, `<r a="1">x</r>`, `{"r":{"$":"x","@a":"1"}}`+"\n", "", exitOk)
+	testRun(t, []string{"-text-key", "$"}, `{"r":{"@a":1,"$":"x"}}`, "<r a=\"1\">x</r>\n", "", exitOk)
+	testRun(t, []string{"-text-object"}, `<r>x</r>`, `{"r":{"#text":"x"}}`+"\n", "", exitOk)
+	testRun(t, []string{"-convention", "badgerfish", "-text-key", "#text"}, `<r>x</r>`, `{"r":{"#text":"x"}}`+"\n", "", exitOk)
+	testRun(t, []string{"-ns-map", "a=urn:a"}, `<r xmlns="urn:a"/>`, `{"a:r":{"@xmlns:a":"urn:a"}}`+"\n", "", exitOk)
+	testRun(t, []string{"-ns-map", "a=urn:a"}, `{"urn:a:r":1}`, "<a:r xmlns:a=\"urn:a\">1</a:r>\n", "", exitOk)
+	testRun(t, []string{"-entity", "me=xqml", "-entity", "eq=a=b"}, `<r>&me; &eq;</r>`, `{"r":"xqml a=b"}`+"\n", "", exitOk)
+	testRun(t, []string{"-declaration", "-version", "1.1", "-standalone", "yes"}, `{"r":1}`, "<?xml version=\"1.1\" encoding=\"UTF-8\" standalone=\"yes\"?><r>1</r>\n", "", exitOk)
+	// query
+	testRun(t, []string{"query", "//e[@id=2]/#text"}, `<r><e id="1">a</e><e id="2">b</e></r>`, "\"b\"\n", "", exitOk)
+	testRun(t, []string{"query", "-partials", "-first", "/r/e"}, `<r><e>1</e><e>2</e></r><r><e>3</e></r>`, "1\n3\n", "", exitOk)
+	testRun(t, []string{"query", "r["}, ``, "", "xqml: invalid query 'r[': missing ']'\n", exitUsage)
+	// analyze
+	testRun(t, []string{"analyze", "-config", "-partials"}, `<r><e>1</e><e>a</e></r><r><e>2</e></r>`, `{"forceList":["r.e"],"castRules":["r.e=string"]}`+"\n", "", exitOk)
+	testRun(t, []string{"analyze", "-schema"}, `<r>1</r>`, `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{"r":{"type":"integer"}},"required":["r"]}`+"\n", "", exitOk)
+	// gen
+	testRun(t, []string{"gen", "-package", "p"}, `<r a="1"><e>x</e><e>y</e></r>`, "// Code generated by xqml. DO NOT EDIT.\n\npackage p\n\nimport \"encoding/xml\"\n\ntype R struct {\n\tXMLName xml.Name `xml:\"r\" xqml:\"-\"`\n\tA       int64    `xml:\"a,attr\" xqml:\"@a\"`\n\tE       []string `xml:\"e\" xqml:\"e\"`\n}\n", "", exitOk)
+	// errors
+	testRun(t, nil, `<r>1</r><r>`, "", "xqml: -:1:9: invalid XML element 'r' found for non-partial parse\n", exitError)
+	testRun(t, []string{"-cast-rule", "e=int"}, "<r>\n<e>x</e></r>", "", "xqml: -:2:4: r.e: invalid int value 'x' at 'r.e'\n", exitError)
+	testRun(t, []string{"-max-depth", "2"}, `<r><e><f/></e></r>`, "", "xqml: -:1:7: r.e.f: maximum depth exceeded (2)\n", exitError)
+	testRun(t, nil, `x`, "", "xqml: -: cannot detect input format, use -to\n", exitError)
+	testRun(t, []string{"-to", "yaml"}, ``, "", "xqml: invalid -to 'yaml', must be json or xml\n", exitUsage)
+}
+
+func testRun(t *testing.T, args []string, stdin string, stdout string, stderr string, code int) {
+	t.Logf("")
+	t.Logf("xqml %v: %s => %s", args, stdin, stdout)
+	out := new(bytes.Buffer)
+	err := new(bytes.Buffer)
+	res := run(args, strings.NewReader(stdin), out, err)
+	if res != code {
+		t.Errorf("ERROR: received exit code %d", res)
+	}
+	if out.String() != stdout {
+		t.Errorf("ERROR: received %s", out.String())
+	}
+	if err.String() != stderr {
+		t.Errorf("ERROR: received error %s", err.String())
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	xsd := filepath.Join(t.TempDir(), "r.xsd")
+	_ = os.WriteFile(xsd, []byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:element name="r" type="xs:int"/></xs:schema>`), 0o644)
+	testRun(t, []string{"validate", "-xsd", xsd}, `<r>1</r>`, "", "", exitOk)
+	testRun(t, []string{"validate", "-xsd", xsd, "-partials"}, `<r>1</r><r>x</r><r><e/></r>`, "", "xqml: -:1:9: r: invalid int value 'x'\nxqml: -:1:20: r.e: unexpected element 'e', text only is expected\n", exitError)
+	testRun(t, []string{"validate"}, ``, "", "xqml: missing -xsd\n", exitUsage)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/momiji/xqml"
+)
+
+// query prints the values matching a query in XML documents, one JSON value per line.
+func query(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	o := &options{}
+	fs := flag.NewFlagSet("xqml query", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: xqml query [flags] expr [file...]\n\n")
+		fmt.Fprintf(stderr, "Prints the values matching a query like \"//e[@id='3']/#text\", one JSON value per line.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	addDecoderFlags(fs, o)
+	first := fs.Bool("first", false, "print only the first matching value of each document")
+	fs.StringVar(&o.indent, "indent", "", "output indentation")
+	err := fs.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOk
+		}
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	conv, ok := conventions[o.convention]
+	if o.convention == "" {
+		conv, ok = xqml.Xqml, true
+	}
+	if !ok {
+		fmt.Fprintf(stderr, "xqml: invalid -convention '%s'\n", o.convention)
+		return exitUsage
+	}
+	q, err := xqml.CompileQuery(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "xqml: %v\n", err)
+		return exitUsage
+	}
+	q.SetConvention(conv)
+	return openFiles(fs.Args()[1:], stdin, stderr, func(name string, reader io.Reader) error {
+		d, err := o.newDecoder(reader)
+		if err != nil {
+			return err
+		}
+		j := o.newJsonEncoder(stdout)
+		for {
+			var v any
+			err = d.Decode(&v)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			res := q.Select(v)
+			if *first && len(res) > 1 {
+				res = res[:1]
+			}
+			for _, r := range res {
+				err = j.Encode(r)
+				if err != nil {
+					return err
+				}
+			}
+			if !o.partials {
+				return nil
+			}
+		}
+	})
+}
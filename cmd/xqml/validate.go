@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/momiji/xqml"
+)
+
+// validate validates XML documents against an XML Schema, printing violations to stderr.
+func validate(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("xqml validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: xqml validate -xsd file [flags] [file...]\n\n")
+		fmt.Fprintf(stderr, "Validates XML documents against an XML Schema, printing violations as file:line:column: path: message.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	xsd := fs.String("xsd", "", "XML Schema file")
+	failFast := fs.Bool("fail-fast", false, "stop on the first violation of each document")
+	partials := fs.Bool("partials", false, "read multiple XML documents")
+	err := fs.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOk
+		}
+		return exitUsage
+	}
+	if *xsd == "" {
+		fmt.Fprintf(stderr, "xqml: missing -xsd\n")
+		return exitUsage
+	}
+	schema, err := xqml.LoadSchemaFile(*xsd)
+	if err != nil {
+		fmt.Fprintf(stderr, "xqml: %v\n", err)
+		return exitError
+	}
+	v := &xqml.Validator{Schema: schema, FailFast: *failFast}
+	invalid := false
+	res := openFiles(fs.Args(), stdin, stderr, func(name string, reader io.Reader) error {
+		d := xqml.NewDecoder(reader)
+		d.Validator = v
+		d.Partials = *partials
+		for {
+			var value any
+			err := d.Decode(&value)
+			var errs xqml.ValidationErrors
+			var verr *xqml.ValidationError
+			switch {
+			case err == io.EOF:
+				return nil
+			case errors.As(err, &errs):
+				for _, e := range errs {
+					fmt.Fprintf(stderr, "xqml: %s:%v\n", name, e)
+				}
+				invalid = true
+			case errors.As(err, &verr):
+				fmt.Fprintf(stderr, "xqml: %s:%v\n", name, verr)
+				invalid = true
+			case err != nil:
+				return err
+			}
+			if !*partials {
+				return nil
+			}
+		}
+	})
+	if res == exitOk && invalid {
+		return exitError
+	}
+	return res
+}
@@ -0,0 +1,86 @@
+package xqml
+
+import "strings"
+
+const (
+	DefaultAttrPrefix = "@"
+	DefaultTextKey    = "#text"
+	MixedKey          = "#mixed"
+	// CDataKey is the key of CDATA sections text, see Decoder.CData.
+	CDataKey = "#cdata"
+	// CommentKey is the key of comments, see Decoder.Comments.
+	CommentKey = "#comment"
+	// ProcInstPrefix is the prefix of processing instructions keys, like "?xml-stylesheet", see Decoder.ProcInsts.
+	ProcInstPrefix = "?"
+	// DirectivePrefix is the prefix of directives keys, like "!doctype", see Decoder.Directives.
+	DirectivePrefix = "!"
+)
+
+// Convention describes how attributes and text are stored in decoded values.
+type Convention struct {
+	// AttrPrefix is the prefix of attributes keys, like "@".
+	AttrPrefix string
+	// AttrKey allows to group attributes in an object stored with this key, instead of using prefixed keys.
+	AttrKey string
+	// TextKey is the key of the element text, like "#text".
+	TextKey string
+	// TextObject forces elements with text only to be stored as objects, with the text stored in TextKey.
+	TextObject bool
+	// Attributes allows to keep attributes.
+	Attributes bool
+}
+
+var (
+	// Xqml is the default convention: {"e":{"@a":"1","#text":"text"}}.
+	Xqml = Convention{AttrPrefix: DefaultAttrPrefix, TextKey: DefaultTextKey, Attributes: true}
+	// XmlToDict is the convention of python xmltodict, which is the same as Xqml.
+	XmlToDict = Xqml
+	// BadgerFish is the BadgerFish convention, where text is always an object: {"e":{"@a":"1","$":"text"}}.
+	BadgerFish = Convention{AttrPrefix: DefaultAttrPrefix, TextKey: "$", TextObject: true, Attributes: true}
+	// Parker is the Parker convention, where attributes are dropped: {"e":"text"}.
+	Parker = Convention{AttrPrefix: DefaultAttrPrefix, TextKey: DefaultTextKey, Attributes: false}
+	// GData is the Google GData convention, where attributes are not prefixed
+	// and text is always an object: {"e":{"a":"1","$t":"text"}}.
+	GData = Convention{AttrPrefix: "", TextKey: "$t", TextObject: true, Attributes: true}
+	// Abdera is the Apache Abdera convention, where attributes are grouped:
+	// {"e":{"attributes":{"a":"1"},"children":"text"}}.
+	// Unlike Abdera, text is not wrapped in a list.
+	Abdera = Convention{AttrKey: "attributes", TextKey: "children", Attributes: true}
+)
+
+// SetConvention sets the decoder attributes and text keys from a convention.
+func (x *Decoder) SetConvention(c Convention) {
+	x.AttrPrefix = c.AttrPrefix
+	x.AttrKey = c.AttrKey
+	x.TextKey = c.TextKey
+	x.TextObject = c.TextObject
+	x.Attributes = c.Attributes
+}
+
+// SetConvention sets the encoder attributes and text keys from a convention.
+func (x *Encoder) SetConvention(c Convention) {
+	x.AttrPrefix = c.AttrPrefix
+	x.AttrKey = c.AttrKey
+	x.TextKey = c.TextKey
+}
+
+// setConvention sets the convention in use, being the default one when decoding into Go values.
+func (x *Decoder) setConvention() {
+	if x.raw {
+		x.conv = Xqml
+		x.conv.Attributes = x.Attributes
+	} else {
+		x.conv = Convention{
+			AttrPrefix: x.AttrPrefix,
+			AttrKey:    x.AttrKey,
+			TextKey:    x.TextKey,
+			TextObject: x.TextObject,
+			Attributes: x.Attributes,
+		}
+	}
+}
+
+// isMisc returns true if a key is a comment, processing instruction or directive key.
+func isMisc(key string) bool {
+	return key == CommentKey || strings.HasPrefix(key, ProcInstPrefix) || strings.HasPrefix(key, DirectivePrefix)
+}
@@ -0,0 +1,81 @@
+package xqml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_Convention(t *testing.T) {
+	src := `<r a="1"><e>x</e><f b="2">y</f><g></g></r>`
+	testConvention(t, Xqml, src, `{"r":{"@a":"1","e":"x","f":{"#text":"y","@b":"2"},"g":null}}`, src)
+	testConvention(t, XmlToDict, src, `{"r":{"@a":"1","e":"x","f":{"#text":"y","@b":"2"},"g":null}}`, src)
+	testConvention(t, BadgerFish, src, `{"r":{"@a":"1","e":{"$":"x"},"f":{"$":"y","@b":"2"},"g":null}}`, src)
+	testConvention(t, Parker, src, `{"r":{"e":"x","f":"y","g":null}}`, `<r><e>x</e><f>y</f><g></g></r>`)
+	testConvention(t, GData, src, `{"r":{"a":"1","e":{"$t":"x"},"f":{"$t":"y","b":"2"},"g":null}}`, src)
+	testConvention(t, Abdera, src, `{"r":{"attributes":{"a":"1"},"e":"x","f":{"attributes":{"b":"2"},"children":"y"},"g":null}}`, src)
+	// custom keys
+	testConvention(t, Convention{AttrPrefix: "-", TextKey: "_text", Attributes: true}, src, `{"r":{"-a":"1","e":"x","f":{"-b":"2","_text":"y"},"g":null}}`, src)
+}
+
+func Test_ConventionStruct(t *testing.T) {
+	type F struct {
+		B    string `xqml:"@b"`
+		Text string `xqml:"#text"`
+	}
+	v := struct {
+		A string `xqml:"@a"`
+		F F      `xqml:"f"`
+	}{"1", F{"2", "y"}}
+	for _, c := range []Convention{Xqml, BadgerFish, GData, Abdera} {
+		writer := new(bytes.Buffer)
+		x := NewEncoder(writer)
+		x.SetConvention(c)
+		x.Root = "r"
+		err := x.Encode(v)
+		if err != nil {
+			t.Errorf("ERROR: %v", err)
+		}
+		if res := writer.String(); res != `<r a="1"><f b="2">y</f></r>` {
+			t.Errorf("ERROR: received %s", res)
+		}
+		// decoding into structs is independent of the convention
+		var w F
+		d := NewDecoder(strings.NewReader(`<f b="2">y</f>`))
+		d.SetConvention(c)
+		err = d.Decode(&w)
+		if err != nil {
+			t.Errorf("ERROR: %v", err)
+		}
+		if w != v.F {
+			t.Errorf("ERROR: received %v", w)
+		}
+	}
+}
+
+func testConvention(t *testing.T, c Convention, src string, rjson string, rxml string) {
+	t.Logf("")
+	t.Logf("xml => json: %s => %s\n", src, rjson)
+	d := NewDecoder(strings.NewReader(src))
+	d.SetConvention(c)
+	var v map[string]any
+	err := d.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	res := Stringify(v)
+	if res != rjson {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+	t.Logf("json => xml: %s => %s\n", rjson, rxml)
+	writer := new(bytes.Buffer)
+	x := NewEncoder(writer)
+	x.SetConvention(c)
+	err = x.Encode(v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res = writer.String(); res != rxml {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
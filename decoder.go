@@ -4,6 +4,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"reflect"
 	"strings"
 )
 
@@ -12,21 +13,137 @@ type Decoder struct {
 	Attributes bool
 	// Namespaces allows to keep namespaces. Default is true.
 	Namespaces bool
+	// NsPrefixes allows to name elements and attributes with their document prefix, like "atom:feed", instead of their namespace URI.
+	// Requires Namespaces. Default is false.
+	NsPrefixes bool
+	// NsMap allows to map namespace URIs to prefixes, like {"http://www.w3.org/2005/Atom": "atom"}.
+	// It takes precedence over document prefixes, and renames xmlns declarations accordingly. Default is nil.
+	NsMap map[string]string
+	// NsDeclarations allows to keep xmlns namespace declarations as attributes. Default is true.
+	NsDeclarations bool
+	// AttrPrefix allows to set the prefix of attributes keys. Default is "@".
+	AttrPrefix string
+	// AttrKey allows to group attributes in an object stored with this key, instead of using prefixed keys. Default is "".
+	AttrKey string
+	// TextKey allows to set the key of the element text. Default is "#text".
+	TextKey string
+	// TextObject forces elements with text only to be objects, with the text stored in TextKey. Default is false.
+	TextObject bool
 	// ForceList allows to force some elements to be parsed as slices, even when only one element is present.
 	// Supports "r.x" paths notation and "x" element names. Multiple values can be passed as comma separated values, like "r.x,r.y,z".
 	ForceList []string
 	// Html allows HTML content, by auto-closing known HTML tags. Default is false.
 	Html bool
+	// Html5 allows HTML content, by parsing it with the HTML5 tree building rules, like browsers do: unquoted attributes,
+	// implicit elements like <html>, <body> or <tbody>, unclosed elements like <p> or <li>, and stray end tags are supported.
+	// The input encoding is detected from its BOM or <meta> charset, then converted with CharsetReader, and is UTF-8 otherwise.
+	// The whole input is parsed on first Decode, and errors positions are not available. It takes precedence over Html.
+	// Default is false.
+	Html5 bool
 	// Partials allow to call Decode() multiple times to return multiple XML files. When true, Decode() can be called until io.EOF is reached. Default is false.
 	Partials bool
 	// Cast allows to cast values to boolean/int/float. Default is true.
 	Cast bool
+	// UseNumber allows to cast numbers to json.Number instead of int64/uint64/float64, keeping their exact text,
+	// like "1.10" or "12345678901234567890.123". Numbers which are not valid JSON numbers, like "01234", are kept as strings.
+	// Default is false.
+	UseNumber bool
+	// CastRules allows to set cast types by path, like "r.id=string", "r.price=decimal" or "*.enabled=bool".
+	// Supports "r.x" paths, "x" element names, "r.x.@a" and "@a" attributes, and "*" wildcards matching any characters.
+	// Types are string, int, uint, float, decimal, number, bool and auto, decimal and number being json.Number values.
+	// Rules take precedence over Cast, and apply even if Cast is false. Multiple values can be passed as comma separated values.
+	CastRules []string
+	// CastAttrs allows to cast attributes values like text values when Cast is true. Default is false.
+	CastAttrs bool
+	// Caster allows to cast text and attributes values with a custom function. It takes precedence over CastRules and Cast.
+	Caster Caster
+	// Schema allows to use an XML Schema to force lists, cast values and add default attributes.
+	// Its types take precedence over Cast, but not over CastRules and Caster. Default is nil.
+	Schema *Schema
+	// Validator allows to validate documents while decoding them. Default is nil.
+	// Violations are returned after the decoded value is stored, or as soon as found with Validator.FailFast.
+	Validator *Validator
+	// MaxDepth allows to limit the depth of elements, 1 being the root element. Default is 0, meaning no limit.
+	MaxDepth int
+	// MaxElements allows to limit the number of elements of each document. Default is 0, meaning no limit.
+	MaxElements int
+	// MaxAttributes allows to limit the number of attributes of each element, including namespace declarations.
+	// It is checked once the element start is read, so it does not bound the memory used to read it, see MaxInputBytes.
+	// Default is 0, meaning no limit.
+	MaxAttributes int
+	// MaxTextBytes allows to limit the size of the text of each element, including blank text between child elements.
+	// It is checked once each text is read, so it does not bound the memory used to read it, see MaxInputBytes.
+	// Default is 0, meaning no limit.
+	MaxTextBytes int
+	// MaxInputBytes allows to limit the size of the whole input, including all documents when Partials is true.
+	// Default is 0, meaning no limit.
+	MaxInputBytes int64
+	// Entities allows to add custom entities, like {"nbsp": "\u00a0"}, to the predefined XML and HTML entities. Default is nil.
+	Entities map[string]string
+	// MaxEntityExpansions allows to limit the number of expanded entity references of the whole input, like "&nbsp;",
+	// except predefined XML entities like "&amp;", unknown entities and references in comments, CDATA sections,
+	// processing instructions and entity declarations. The error is reported at the text or element containing
+	// the exceeding reference, or when reading the input with Html5. Default is 0, meaning no limit.
+	MaxEntityExpansions int
+	// Whitespace allows to set how whitespace of text is handled: WhitespaceTrim trims leading and trailing whitespace,
+	// WhitespacePreserve keeps text as is and WhitespaceCollapse also replaces runs of whitespace by a single space.
+	// Whitespace only text is dropped, except for preserved elements without child elements. Default is "trim".
+	Whitespace string
+	// WhitespaceRules allows to set whitespace modes by path, like "r.pre=preserve" or "*.code=preserve", as CastRules.
+	// Modes are inherited by child elements, and rules take precedence over xml:space and Whitespace. Default is nil.
+	WhitespaceRules []string
+	// XmlSpace allows to honor xml:space attributes, "preserve" preserving whitespace of the element and its children,
+	// and "default" restoring Whitespace. Default is false.
+	XmlSpace bool
+	// CharsetReader allows to decode documents with a non-UTF-8 encoding declaration, like encoding="ISO-8859-1",
+	// by converting their input to UTF-8. Default is NewCharsetReader, supporting common single-byte and CJK encodings.
+	CharsetReader CharsetReader
 	// Sep allows to set text separator between multiple CDATA. Default is " ".
-	Sep         string
-	decoder     *xml.Decoder
-	forceList   map[string]bool
-	done        bool
-	initialized bool
+	Sep string
+	// Ordered allows to keep elements and attributes order, by returning *OrderedMap instead of map[string]any.
+	// Elements with the same name separated by other elements, like <a/><b/><a/>, are stored as a "#mixed" ordered list
+	// of single key segments, like {"#mixed":[{"a":null},{"b":null},{"a":null}]}. Default is false.
+	Ordered bool
+	// Mixed allows to keep elements with mixed content in order, by storing text and elements as an ordered list of segments in "#mixed".
+	// Text segments are kept as is, without trimming nor casting. Default is false.
+	Mixed bool
+	// CData allows to keep the text of CDATA sections in "#cdata" keys, as is, without trimming nor casting,
+	// multiple sections being concatenated. With Mixed, CDATA sections of mixed content are kept as text segments.
+	// Default is false.
+	CData bool
+	// Comments allows to keep comments in "#comment" keys, multiple comments being stored as a list.
+	// Their position among elements is only kept with Ordered, see Encoder.Encode. Default is false.
+	Comments bool
+	// ProcInsts allows to keep processing instructions in "?target" keys, like "?xml-stylesheet",
+	// including the XML declaration in "?xml". Default is false.
+	ProcInsts bool
+	// Directives allows to keep directives in "!name" keys, the name being lower case,
+	// like "!doctype" with value "html" for <!DOCTYPE html>. Default is false.
+	Directives bool
+	// ItemDepth allows Stream() to return elements found at this depth, 1 being the root element. Default is 0.
+	ItemDepth int
+	// ItemPath allows Stream() to return elements matching some paths.
+	// Supports "r.x" paths notation and "x" element names, like ForceList.
+	ItemPath        []string
+	decoder         *xml.Decoder
+	input           *inputReader
+	elements        int
+	forceList       map[string]bool
+	schemaLists     map[string]bool
+	castRules       *pathRules
+	whitespaceRules *pathRules
+	itemPath        map[string]bool
+	validation      *validation
+	analyzer        *Analyzer
+	offset          int64
+	line            int
+	column          int
+	conv            Convention
+	ns              []nsBinding
+	stream          StreamFunc
+	raw             bool
+	done            bool
+	initialized     bool
 }
 
 // NewDecoder returns a new decoder that reads from r.
@@ -34,73 +151,205 @@ type Decoder struct {
 // The decoder introduces its own buffering and may read
 // data from r beyond the XML values requested.
 func NewDecoder(reader io.Reader) *Decoder {
-	decoder := xml.NewDecoder(reader)
+	input := &inputReader{reader: reader}
+	decoder := xml.NewDecoder(input)
 	decoder.Strict = false
 	decoder.Entity = xml.HTMLEntity
-	return &Decoder{
-		Attributes:  true,
-		Namespaces:  true,
-		ForceList:   nil,
-		Html:        false,
-		Cast:        true,
-		Sep:         " ",
-		Partials:    false,
-		decoder:     decoder,
-		forceList:   nil,
-		done:        false,
-		initialized: false,
+	x := &Decoder{
+		Attributes:      true,
+		Namespaces:      true,
+		NsPrefixes:      false,
+		NsMap:           nil,
+		NsDeclarations:  true,
+		AttrPrefix:      DefaultAttrPrefix,
+		AttrKey:         "",
+		TextKey:         DefaultTextKey,
+		TextObject:      false,
+		ForceList:       nil,
+		Html:            false,
+		Html5:           false,
+		Cast:            true,
+		UseNumber:       false,
+		CastRules:       nil,
+		CastAttrs:       false,
+		Caster:          nil,
+		Schema:          nil,
+		Validator:       nil,
+		MaxDepth:        0,
+		MaxElements:     0,
+		MaxAttributes:   0,
+		MaxTextBytes:    0,
+		MaxInputBytes:   0,
+		Entities:        nil,
+		Whitespace:      WhitespaceTrim,
+		WhitespaceRules: nil,
+		XmlSpace:        false,
+		CharsetReader:   NewCharsetReader,
+		Sep:             " ",
+		Partials:        false,
+		Ordered:         false,
+		Mixed:           false,
+		CData:           false,
+		Comments:        false,
+		ProcInsts:       false,
+		Directives:      false,
+		ItemDepth:       0,
+		ItemPath:        nil,
+		decoder:         decoder,
+		input:           input,
+		forceList:       nil,
+		done:            false,
+		initialized:     false,
 	}
+	input.x = x
+	return x
 }
 
 // SetReadForceList allows to ensure some elements are parsed as slice, even when only one element is present.
 // Use "x" for element name at any path, or "r.x" path. Also supports multiple commma separated paths at once, like "a.b,a.c,d".
 func (x *Decoder) setForceList() {
-	x.forceList = make(map[string]bool)
-	for _, a := range x.ForceList {
+	x.forceList = newPaths(x.ForceList)
+}
+
+// newPaths returns the set of paths, splitting comma separated values.
+func newPaths(paths []string) map[string]bool {
+	res := make(map[string]bool)
+	for _, a := range paths {
 		for _, b := range strings.Split(a, ",") {
-			x.forceList[b] = true
+			res[b] = true
+		}
+	}
+	return res
+}
+
+// init initializes the decoder on first use.
+func (x *Decoder) init() error {
+	if !x.initialized {
+		if x.Html5 {
+			x.decoder = xml.NewTokenDecoder(&htmlReader{x: x})
+		}
+		if x.Html {
+			x.decoder.AutoClose = xml.HTMLAutoClose
+		}
+		if len(x.Entities) > 0 {
+			entities := make(map[string]string, len(xml.HTMLEntity)+len(x.Entities))
+			for k, v := range xml.HTMLEntity {
+				entities[k] = v
+			}
+			for k, v := range x.Entities {
+				entities[k] = v
+			}
+			x.decoder.Entity = entities
+		}
+		if x.CharsetReader != nil {
+			x.decoder.CharsetReader = x.input.charsetReader
+		}
+		x.setForceList()
+		x.itemPath = newPaths(x.ItemPath)
+		rules, err := newCastRules(x.CastRules)
+		if err != nil {
+			return err
 		}
+		x.castRules = rules
+		switch x.Whitespace {
+		case "":
+			x.Whitespace = WhitespaceTrim
+		case WhitespaceTrim, WhitespacePreserve, WhitespaceCollapse:
+		default:
+			return fmt.Errorf("invalid whitespace mode '%s'", x.Whitespace)
+		}
+		rules, err = newWhitespaceRules(x.WhitespaceRules)
+		if err != nil {
+			return err
+		}
+		x.whitespaceRules = rules
+		x.initialized = true
 	}
+	return nil
 }
 
 // Decode reads the next XML-encoded value from its input
 // and stores it in the value pointed to by v.
 //
-// See the documentation for Unmarshal for details about the
-// conversion of XML into a Go value.
+// When v is a *map[string]any or a *any, the whole document is stored.
+// Otherwise, v must be a pointer to a struct, slice, map or scalar value,
+// and the content of the root element is stored in it, using xqml struct tags:
+// "x" for an element, "@x" for an attribute, "#text" for the element text and "a.b" for a path.
+// Values are then casted according to the target type.
+//
+// Errors found while reading the input are returned as *DecodeError, with their position.
 func (x *Decoder) Decode(v any) error {
 	// check input value is a valid pointer
+	generic := false
 	switch v.(type) {
 	case *any:
+		generic = true
 	case *map[string]any:
+		if x.Ordered {
+			return fmt.Errorf("invalid argument, must be a *OrderedMap or *any when Ordered is true")
+		}
+		generic = true
+	case *OrderedMap:
+		if !x.Ordered {
+			return fmt.Errorf("invalid argument, must be a *map[string]any or *any when Ordered is false")
+		}
+		generic = true
 	default:
-		return fmt.Errorf("invalid argument, must be a *map[string]any or *any")
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Pointer || rv.IsNil() {
+			return fmt.Errorf("invalid argument, must be a non-nil pointer")
+		}
 	}
 	// initialize
-	if !x.initialized {
-		if x.Html {
-			x.decoder.AutoClose = xml.HTMLAutoClose
-		}
-		x.setForceList()
-		x.initialized = true
+	err := x.init()
+	if err != nil {
+		return err
 	}
+	x.raw = !generic
+	x.setConvention()
+	x.newValidation()
+	x.elements = 0
+	x.schemaLists = make(map[string]bool)
 	// parse input
-	root := map[string]any{}
+	root := x.newNode()
 	curr := elem{data: root, content: ContentObject}
-	err := x.parse(&curr, nil)
+	err = x.parse(&curr, nil)
 	if err != nil {
 		return err
 	}
 	// set return value
 	switch v.(type) {
 	case *any:
-		*(v.(*any)) = root
+		*(v.(*any)) = root.value()
 	case *map[string]any:
-		*(v.(*map[string]any)) = root
+		*(v.(*map[string]any)) = root.value().(map[string]any)
+	case *OrderedMap:
+		*(v.(*OrderedMap)) = *root.value().(*OrderedMap)
+	default:
+		if root.Len() > 0 {
+			var content any
+			for k, e := range root.value().(map[string]any) {
+				if !isMisc(k) {
+					content = e
+				}
+			}
+			err = x.unmarshal(content, reflect.ValueOf(v).Elem(), "")
+			if err != nil {
+				return err
+			}
+		}
 	}
 	// return
-	if x.Partials && len(root) == 0 {
+	if x.Partials && curr.count == 0 {
 		return io.EOF
 	}
-	return nil
+	return x.validation.result()
+}
+
+// newValidation resets the validation state of the next document.
+func (x *Decoder) newValidation() {
+	x.validation = nil
+	if x.Validator != nil {
+		x.validation = newValidation(x.Validator)
+	}
 }
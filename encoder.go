@@ -2,7 +2,12 @@ package xqml
 
 import (
 	"encoding/xml"
+	"fmt"
 	"io"
+	"regexp"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
 )
 
 const (
@@ -16,8 +21,49 @@ type Encoder struct {
 	// Root allows to set root element name. Default is "root".
 	Root string
 	// Element allows to set root.element element name. Default is "element".
-	Element     string
-	encoder     *xml.Encoder
+	Element string
+	// AttrPrefix allows to set the prefix of attributes keys. Default is "@".
+	// When empty, scalar values are written as attributes and other values as elements.
+	AttrPrefix string
+	// AttrKey allows to read attributes from an object stored with this key, instead of using prefixed keys. Default is "".
+	AttrKey string
+	// TextKey allows to set the key of the element text. Default is "#text".
+	TextKey string
+	// NsMap allows to map namespace URIs to prefixes, like {"http://www.w3.org/2005/Atom": "atom"}.
+	// It is used to declare prefixes used in names, like "atom:feed", and to convert namespace URIs in names,
+	// like "http://www.w3.org/2005/Atom:feed", to prefixes. Prefixes neither declared nor in NsMap are errors.
+	// Default is nil.
+	NsMap map[string]string
+	// CDataPaths allows to write the text of some elements as CDATA sections, like "<![CDATA[a < b]]>".
+	// Supports "r.x" paths notation and "x" element names, like Decoder.ForceList. Default is nil.
+	CDataPaths []string
+	// Declaration allows to write an XML declaration, like <?xml version="1.0" encoding="UTF-8"?>, before the document.
+	// A "?xml" key of the document is then ignored. Default is false.
+	Declaration bool
+	// Version allows to set the version of the XML declaration. Default is "1.0".
+	Version string
+	// Encoding allows to set the output encoding, like "ISO-8859-1", "windows-1252" or "Shift_JIS", see NewCharsetReader.
+	// With an encoding other than UTF-8, the XML declaration is always written, and characters the encoding does not support
+	// are written as character references, like "&#8364;". Default is "UTF-8".
+	Encoding string
+	// Html allows to write HTML void elements, like <br> or <img>, without end tag, their content being ignored.
+	// Default is false, writing <br></br>.
+	Html bool
+	// Standalone allows to set the standalone flag of the XML declaration, "yes" or "no". Default is "", meaning no flag.
+	Standalone string
+	writer     io.Writer
+	encoder    *xml.Encoder
+	// output is the writer converting output to Encoding, nil for UTF-8
+	output *transform.Writer
+	// charset is the encoder of Encoding, to check characters of CDATA sections, comments and processing instructions
+	charset *encoding.Encoder
+	// html is the writer dropping end tags of void elements, nil if Html is false
+	html       *htmlWriter
+	cdataPaths map[string]bool
+	// paths are the paths of the elements being written
+	paths       []string
+	ns          []nsBinding
+	nsCount     int
 	initialized bool
 }
 
@@ -27,28 +73,99 @@ type Encoder struct {
 func NewEncoder(writer io.Writer) *Encoder {
 	encoder := xml.NewEncoder(writer)
 	return &Encoder{
-		Indent:  "",
-		Root:    DefaultRootTag,
-		Element: DefaultElementTag,
-		encoder: encoder,
+		Indent:      "",
+		Root:        DefaultRootTag,
+		Element:     DefaultElementTag,
+		AttrPrefix:  DefaultAttrPrefix,
+		AttrKey:     "",
+		TextKey:     DefaultTextKey,
+		NsMap:       nil,
+		CDataPaths:  nil,
+		Declaration: false,
+		Version:     "1.0",
+		Encoding:    "UTF-8",
+		Standalone:  "",
+		Html:        false,
+		writer:      writer,
+		encoder:     encoder,
 	}
 }
 
 // Encode writes the XML encoding of v to the stream.
 //
-// See the documentation for Marshal for details about the conversion of Go
-// values to XML.
+// Values can be generic map[string]any and []any values, or any Go value:
+// structs are encoded using xqml struct tags, "x" for an element, "@x" for an attribute,
+// "#text" for the element text and "a.b" for a path, and typed maps and slices are
+// encoded like their generic equivalent.
+//
+// Texts in "#cdata" keys are written as CDATA sections, see Decoder.CData.
+// Comments, processing instructions and directives keys, like "#comment", "?xml-stylesheet" and "!doctype",
+// are written as such, see Decoder.Comments, Decoder.ProcInsts and Decoder.Directives.
+// As map keys are sorted, they are written before the elements of the same parent, including outside of the root element:
+// use *OrderedMap, see Decoder.Ordered, to keep comments and processing instructions found after the root element in place.
 func (x *Encoder) Encode(value any) error {
 	// initialize
 	if !x.initialized {
+		if !isUTF8(x.Encoding) {
+			enc, err := charsetEncoding(x.Encoding)
+			if err != nil {
+				return err
+			}
+			x.output = transform.NewWriter(x.writer, encoding.HTMLEscapeUnsupported(enc.NewEncoder()))
+			x.writer = x.output
+			x.charset = enc.NewEncoder()
+			x.encoder = xml.NewEncoder(x.output)
+		}
+		if x.Html {
+			x.html = &htmlWriter{writer: x.writer}
+			x.encoder = xml.NewEncoder(x.html)
+		}
 		x.encoder.Indent("", x.Indent)
+		x.cdataPaths = newPaths(x.CDataPaths)
 		x.initialized = true
 	}
+	if x.Declaration || x.output != nil {
+		err := x.encoder.EncodeToken(x.declaration())
+		if err == nil && x.Indent != "" {
+			err = x.encoder.EncodeToken(xml.CharData("\n"))
+		}
+		if err != nil {
+			return err
+		}
+	}
 	// write output
 	err := x.write(value)
 	if err != nil {
 		return err
 	}
 	// return
-	return x.encoder.Close()
+	err = x.encoder.Close()
+	if err == nil && x.output != nil {
+		err = x.output.Close()
+	}
+	return err
+}
+
+// declaration returns the XML declaration.
+func (x *Encoder) declaration() xml.ProcInst {
+	inst := fmt.Sprintf(`version="%s" encoding="%s"`, x.Version, x.Encoding)
+	if x.Standalone != "" {
+		inst += fmt.Sprintf(` standalone="%s"`, x.Standalone)
+	}
+	return xml.ProcInst{Target: xmlPrefix, Inst: []byte(inst)}
+}
+
+// xmlEncoding matches the encoding pseudo-attribute of an XML declaration.
+var xmlEncoding = regexp.MustCompile(`\s*encoding\s*=\s*("[^"]*"|'[^']*')`)
+
+// prologDeclaration returns the value of a "?xml" key, with its encoding replaced by Encoding, or removed if it is empty.
+func (x *Encoder) prologDeclaration(value any) any {
+	if value == nil {
+		return nil
+	}
+	encoding := ""
+	if x.Encoding != "" {
+		encoding = fmt.Sprintf(` encoding="%s"`, x.Encoding)
+	}
+	return xmlEncoding.ReplaceAllLiteralString(fmt.Sprintf("%v", value), encoding)
 }
@@ -0,0 +1,284 @@
+package xqml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Errors returned when decoder limits are exceeded, wrapped in a *LimitError and a *DecodeError.
+var (
+	ErrMaxDepth            = errors.New("maximum depth exceeded")
+	ErrMaxElements         = errors.New("maximum number of elements exceeded")
+	ErrMaxAttributes       = errors.New("maximum number of attributes exceeded")
+	ErrMaxTextBytes        = errors.New("maximum text size exceeded")
+	ErrMaxInputBytes       = errors.New("maximum input size exceeded")
+	ErrMaxEntityExpansions = errors.New("maximum number of entity expansions exceeded")
+)
+
+// DecodeError is an error found while decoding a document, with its position in the input.
+type DecodeError struct {
+	// Offset is the byte offset of the token where the error was found, see xml.Decoder.InputOffset.
+	Offset int64
+	// Line and Column are the position of the token where the error was found, starting at 1.
+	Line   int
+	Column int
+	// Path is the dotted path of the element where the error was found, like "r.e", or "" outside of the root element.
+	Path string
+	// Token is the token where the error was found, or nil if the error was found while reading it.
+	Token xml.Token
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%d:%d: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("%d:%d: %s: %v", e.Line, e.Column, e.Path, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// LimitError is an exceeded decoder limit, wrapped in a *DecodeError.
+// It unwraps to its limit error, like ErrMaxDepth, to be checked with errors.Is.
+type LimitError struct {
+	// Err is the limit error, like ErrMaxDepth.
+	Err error
+	// Max is the value of the exceeded limit.
+	Max int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v (%d)", e.Err, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// newError returns a DecodeError at the position of the current token.
+func (x *Decoder) newError(path string, token xml.Token, err error) error {
+	if token != nil {
+		token = xml.CopyToken(token)
+	}
+	return &DecodeError{Offset: x.offset, Line: x.line, Column: x.column, Path: path, Token: token, Err: err}
+}
+
+// limitError returns a DecodeError for an exceeded limit.
+func (x *Decoder) limitError(path string, token xml.Token, err error, max int64) error {
+	return x.newError(path, token, &LimitError{Err: err, Max: max})
+}
+
+// inputReader reads the decoder input, enforcing MaxInputBytes and counting entity references for MaxEntityExpansions.
+// As the input is buffered, MaxInputBytes is checked on bytes read ahead of the current token, while the offset of
+// the entity reference exceeding MaxEntityExpansions is kept to report the error on the token containing it.
+type inputReader struct {
+	reader   io.Reader
+	x        *Decoder
+	bytes    int64
+	entities int
+	// exceeded is the offset after the entity reference exceeding MaxEntityExpansions, if overLimit is true
+	exceeded  int64
+	overLimit bool
+	// entity is the name of the entity reference being read, if inEntity is true
+	entity   []byte
+	inEntity bool
+	// markup are the last bytes read, to find comments, CDATA sections and processing instructions,
+	// and markupEnd is the end of the one being read, entity references not being counted inside
+	markup    []byte
+	markupEnd string
+	// cdata are the offsets of CDATA sections, if Decoder.CData is true, and cdataMatch the length of "<![CDATA[" being read
+	cdata      []int64
+	cdataMatch int
+	// converted is true when the input is converted by Decoder.CharsetReader, CDATA sections and entity references
+	// being then found in converted input
+	converted bool
+	err       error
+}
+
+// maxEntityName is the maximum length of entity names, longer names not being counted as entity references.
+const maxEntityName = 64
+
+func (r *inputReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	max := r.x.MaxInputBytes
+	if max > 0 && int64(len(p)) > max-r.bytes+1 {
+		// read one more byte to detect the limit is exceeded
+		p = p[:max-r.bytes+1]
+	}
+	n, err := r.reader.Read(p)
+	offset := r.bytes
+	r.bytes += int64(n)
+	if max > 0 && r.bytes > max {
+		n -= int(r.bytes - max)
+		r.bytes = max
+		r.err = &LimitError{Err: ErrMaxInputBytes, Max: max}
+		err = r.err
+	}
+	if !r.converted {
+		r.scan(p[:n], offset)
+	}
+	if r.overLimit && r.x.Html5 {
+		// HTML5 documents are parsed at once, without token positions
+		r.err = &LimitError{Err: ErrMaxEntityExpansions, Max: int64(r.x.MaxEntityExpansions)}
+		return 0, r.err
+	}
+	return n, err
+}
+
+// scan records the offsets of CDATA sections and counts entity references found in p, read at offset.
+func (r *inputReader) scan(p []byte, offset int64) {
+	if r.x.CData {
+		r.scanCData(p, offset)
+	}
+	if r.x.MaxEntityExpansions > 0 {
+		r.countEntities(p, offset)
+	}
+}
+
+// entitiesExceeded returns true if the entity reference exceeding MaxEntityExpansions was read before offset.
+func (r *inputReader) entitiesExceeded(offset int64) bool {
+	return r.overLimit && r.exceeded <= offset
+}
+
+// markups are the starts of comments, CDATA sections, processing instructions and entity declarations, with their ends.
+var markups = [][2]string{{"<!--", "-->"}, {cdataStart, "]]>"}, {"<?", "?>"}, {"<!ENTITY", ">"}}
+
+// countEntities counts references to known entities, like "&nbsp;", outside of comments, CDATA sections,
+// processing instructions and entity declarations, keeping the offset of the one exceeding MaxEntityExpansions.
+func (r *inputReader) countEntities(p []byte, offset int64) {
+	for i, b := range p {
+		if len(r.markup) == len(cdataStart) {
+			copy(r.markup, r.markup[1:])
+			r.markup = r.markup[:len(r.markup)-1]
+		}
+		r.markup = append(r.markup, b)
+		if r.markupEnd != "" {
+			if hasSuffix(r.markup, r.markupEnd) {
+				r.markupEnd = ""
+				r.markup = r.markup[:0]
+			}
+			continue
+		}
+		for _, m := range markups {
+			if hasSuffix(r.markup, m[0]) {
+				r.markupEnd = m[1]
+				r.markup = r.markup[:0]
+				r.inEntity = false
+			}
+		}
+		switch {
+		case r.markupEnd != "":
+		case b == '&':
+			r.entity = r.entity[:0]
+			r.inEntity = true
+		case !r.inEntity:
+		case b == ';':
+			if r.isEntity(string(r.entity)) {
+				r.entities++
+				if r.entities > r.x.MaxEntityExpansions && !r.overLimit {
+					r.exceeded = offset + int64(i+1)
+					r.overLimit = true
+				}
+			}
+			r.inEntity = false
+		case isNameByte(b) && len(r.entity) < maxEntityName:
+			r.entity = append(r.entity, b)
+		default:
+			r.inEntity = false
+		}
+	}
+}
+
+// isEntity returns true if name is a known entity expanded by the decoder, predefined XML entities excepted.
+func (r *inputReader) isEntity(name string) bool {
+	switch name {
+	case "", "lt", "gt", "amp", "apos", "quot":
+		return false
+	}
+	if _, ok := r.x.Entities[name]; ok {
+		return true
+	}
+	_, ok := xml.HTMLEntity[name]
+	return ok
+}
+
+// hasSuffix returns true if p ends with s.
+func hasSuffix(p []byte, s string) bool {
+	return len(p) >= len(s) && string(p[len(p)-len(s):]) == s
+}
+
+const cdataStart = "<![CDATA["
+
+// scanCData records the offsets of CDATA sections found in p, read at offset.
+func (r *inputReader) scanCData(p []byte, offset int64) {
+	for i, b := range p {
+		switch {
+		case b == cdataStart[r.cdataMatch]:
+			r.cdataMatch++
+			if r.cdataMatch == len(cdataStart) {
+				r.cdata = append(r.cdata, offset+int64(i+1-len(cdataStart)))
+				r.cdataMatch = 0
+			}
+		case b == '<':
+			r.cdataMatch = 1
+		default:
+			r.cdataMatch = 0
+		}
+	}
+}
+
+// charsetReader converts the input with Decoder.CharsetReader. As decoder offsets then count converted bytes,
+// CDATA sections and entity references are found in converted input, those found after the XML declaration
+// in raw input being dropped.
+func (r *inputReader) charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	reader, err := r.x.CharsetReader(charset, input)
+	if err != nil || (!r.x.CData && r.x.MaxEntityExpansions <= 0) {
+		return reader, err
+	}
+	offset := r.x.decoder.InputOffset()
+	for i, o := range r.cdata {
+		if o >= offset {
+			r.cdata = r.cdata[:i]
+			break
+		}
+	}
+	r.converted = true
+	r.cdataMatch = 0
+	// no entity reference can be found before the XML declaration
+	r.entities, r.overLimit, r.inEntity = 0, false, false
+	r.markup, r.markupEnd = r.markup[:0], ""
+	return &convertedReader{reader: reader, input: r, offset: offset}, nil
+}
+
+// convertedReader reads converted input, recording the offsets of CDATA sections and counting entity references.
+type convertedReader struct {
+	reader io.Reader
+	input  *inputReader
+	offset int64
+}
+
+func (r *convertedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.input.scan(p[:n], r.offset)
+	r.offset += int64(n)
+	return n, err
+}
+
+// isCData returns true if a CDATA section starts at offset, forgetting sections found before.
+func (r *inputReader) isCData(offset int64) bool {
+	for len(r.cdata) > 0 && r.cdata[0] < offset {
+		r.cdata = r.cdata[1:]
+	}
+	return len(r.cdata) > 0 && r.cdata[0] == offset
+}
+
+func isNameByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '_' || b == '-' || b == '.' || b == ':' || b >= 0x80
+}
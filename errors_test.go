@@ -0,0 +1,131 @@
+package xqml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_DecodeError(t *testing.T) {
+	// syntax error
+	testDecodeError(t, nil, "<r>\n  <e><!-x</e></r>", "2:6: r.e: XML syntax error on line 2: invalid sequence <!- not part of <!--", nil)
+	// non-partial parse
+	testDecodeError(t, nil, "<r/>\n<r/>", "2:1: invalid XML element 'r' found for non-partial parse", xml.StartElement{Name: xml.Name{Local: "r"}, Attr: []xml.Attr{}})
+	testDecodeError(t, nil, "<r/>x", "1:5: invalid XML chardata 'x' found for non-partial parse", xml.CharData("x"))
+	// cast errors
+	testDecodeError(t, []string{"r.e=int"}, "<r><e>1</e><e>a</e></r>", "1:15: r.e: invalid int value 'a' at 'r.e'", xml.CharData("a"))
+	testDecodeError(t, []string{"@a=bool"}, "<r><e a=\"x\"/></r>", "1:4: r.e: invalid bool value 'x' at 'r.e.@a'", xml.StartElement{Name: xml.Name{Local: "e"}, Attr: []xml.Attr{{Name: xml.Name{Local: "a"}, Value: "x"}}})
+}
+
+func testDecodeError(t *testing.T, rules []string, src string, expected string, token xml.Token) {
+	t.Logf("")
+	t.Logf("xml => error: %s => %s", src, expected)
+	x := NewDecoder(strings.NewReader(src))
+	x.CastRules = rules
+	var v any
+	err := x.Decode(&v)
+	var derr *DecodeError
+	if !errors.As(err, &derr) {
+		t.Errorf("ERROR: received %v", err)
+		return
+	}
+	if derr.Error() != expected {
+		t.Errorf("ERROR: received %s", derr)
+	}
+	if errors.Unwrap(err) != derr.Err {
+		t.Errorf("ERROR: received unwrapped %v", errors.Unwrap(err))
+	}
+	if fmt.Sprintf("%#v", derr.Token) != fmt.Sprintf("%#v", token) {
+		t.Errorf("ERROR: received token %#v", derr.Token)
+	}
+	if int(derr.Offset) != offset(src, derr.Line, derr.Column) {
+		t.Errorf("ERROR: received offset %d", derr.Offset)
+	}
+}
+
+// offset returns the byte offset of a line and column.
+func offset(s string, line int, column int) int {
+	lines := strings.SplitAfter(s, "\n")
+	res := 0
+	for _, l := range lines[:line-1] {
+		res += len(l)
+	}
+	return res + column - 1
+}
+
+func Test_Limits(t *testing.T) {
+	src := `<r a="1" b="2"><e>abc</e><e>&nbsp;&amp;&copy;</e><e><f/></e></r>`
+	testLimit(t, src, func(x *Decoder) { x.MaxDepth = 2 }, ErrMaxDepth, "1:53: r.e.f: maximum depth exceeded (2)")
+	testLimit(t, src, func(x *Decoder) { x.MaxElements = 4 }, ErrMaxElements, "1:53: r.e.f: maximum number of elements exceeded (4)")
+	testLimit(t, src, func(x *Decoder) { x.MaxAttributes = 1 }, ErrMaxAttributes, "1:1: r: maximum number of attributes exceeded (1)")
+	testLimit(t, src, func(x *Decoder) { x.MaxTextBytes = 2 }, ErrMaxTextBytes, "1:19: r.e: maximum text size exceeded (2)")
+	testLimit(t, src, func(x *Decoder) { x.MaxInputBytes = 20 }, ErrMaxInputBytes, "1:21: r.e: maximum input size exceeded (20)")
+	testLimit(t, src, func(x *Decoder) { x.MaxEntityExpansions = 1 }, ErrMaxEntityExpansions, "1:29: r.e: maximum number of entity expansions exceeded (1)")
+	// limits not exceeded
+	testLimit(t, src, func(x *Decoder) {
+		x.MaxDepth = 3
+		x.MaxElements = 5
+		x.MaxAttributes = 2
+		x.MaxTextBytes = 19
+		x.MaxInputBytes = int64(len(src))
+		x.MaxEntityExpansions = 2
+	}, nil, "")
+	// elements are counted by document
+	x := NewDecoder(strings.NewReader(`<r><e/></r><r><e/></r>`))
+	x.Partials = true
+	x.MaxElements = 2
+	for i := 0; i < 2; i++ {
+		var v any
+		if err := x.Decode(&v); err != nil {
+			t.Errorf("ERROR: %v", err)
+		}
+	}
+	// entity references in comments, CDATA sections and processing instructions are not counted
+	testLimit(t, `<r><!-- &nbsp; --><?pi &nbsp;?><e><![CDATA[&nbsp;]]>&copy;</e></r>`, func(x *Decoder) { x.MaxEntityExpansions = 1 }, nil, "")
+	testLimit(t, `<r><!-- &nbsp; -->&copy;<![CDATA[]]]]>&nbsp;</r>`, func(x *Decoder) { x.MaxEntityExpansions = 1 }, ErrMaxEntityExpansions, "1:39: r: maximum number of entity expansions exceeded (1)")
+	// unknown references and entity declarations are not counted, references in attributes are reported on their element
+	testLimit(t, `<!DOCTYPE r [<!ENTITY e "&nbsp;&copy;">]><r>&x;&y;&copy;</r>`, func(x *Decoder) { x.MaxEntityExpansions = 1 }, nil, "")
+	testLimit(t, "<r>\n  <e a=\"&copy;\"/>\n  <e a=\"&nbsp;\"/></r>", func(x *Decoder) { x.MaxEntityExpansions = 1 }, ErrMaxEntityExpansions, "3:3: r.e: maximum number of entity expansions exceeded (1)")
+	// converted input
+	testLimit(t, "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><r>\xe9\xe9&copy;<e>&nbsp;</e></r>", func(x *Decoder) { x.MaxEntityExpansions = 1 }, ErrMaxEntityExpansions, "1:60: r.e: maximum number of entity expansions exceeded (1)")
+	// limit errors
+	x = NewDecoder(strings.NewReader(src))
+	x.MaxDepth = 2
+	err := x.Decode(new(any))
+	var lerr *LimitError
+	if !errors.As(err, &lerr) || lerr.Err != ErrMaxDepth || lerr.Max != 2 {
+		t.Errorf("ERROR: received %v", err)
+	}
+	// custom entities
+	x = NewDecoder(strings.NewReader(`<r>&x;&nbsp;</r>`))
+	x.Entities = map[string]string{"x": "y"}
+	var v any
+	if err := x.Decode(&v); err != nil || v.(map[string]any)["r"] != "y " {
+		t.Errorf("ERROR: received %v %v", v, err)
+	}
+}
+
+func testLimit(t *testing.T, src string, setup func(x *Decoder), expected error, message string) {
+	t.Logf("")
+	t.Logf("xml => error: %s => %s", src, message)
+	x := NewDecoder(strings.NewReader(src))
+	setup(x)
+	var v any
+	err := x.Decode(&v)
+	if expected == nil {
+		if err != nil {
+			t.Errorf("ERROR: %v", err)
+		}
+		return
+	}
+	var derr *DecodeError
+	if !errors.Is(err, expected) || !errors.As(err, &derr) {
+		t.Errorf("ERROR: received %v", err)
+		return
+	}
+	if err.Error() != message {
+		t.Errorf("ERROR: received %s", err)
+	}
+}
@@ -0,0 +1,318 @@
+package xqml
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Generator generates Go struct definitions of documents, from an Analyzer or a Schema.
+//
+// Structs have both encoding/xml and xqml tags, so they can be used with xml.Unmarshal and Decoder.Decode.
+// Elements with attributes or child elements are structs, the text being stored in a Text field,
+// repeated elements are slices, and optional elements with a struct type are pointers.
+// Element names are the decoder ones, so documents with namespaces should be generated and decoded
+// with NsPrefixes or without Namespaces, as namespace URIs are not valid in xqml tags paths.
+//
+//	a := NewAnalyzer()
+//	err := a.Analyze(NewDecoder(reader))
+//	...
+//	code, err := NewGenerator("feed").Analyzer(a)
+type Generator struct {
+	// Package is the package name of the generated code. Default is "main".
+	Package string
+	// structs are the generated structs, in order
+	structs []*goStruct
+	// names are the names of generated types
+	names map[string]bool
+	// schemaTypes are the structs generated for schema elements, to reuse them for recursive and referenced elements
+	schemaTypes map[*schemaElement]string
+}
+
+type goStruct struct {
+	name    string
+	xmlName string
+	fields  []*goField
+	// names are the fields names
+	names map[string]bool
+}
+
+type goField struct {
+	name string
+	typ  string
+	xml  string
+	xqml string
+}
+
+// goTypes are the Go types of cast types, decimals and numbers being json.Number to keep their precision.
+var goTypes = map[string]string{
+	CastString:  "string",
+	CastInt:     "int64",
+	CastUint:    "uint64",
+	CastFloat:   "float64",
+	CastDecimal: "json.Number",
+	CastNumber:  "json.Number",
+	CastBool:    "bool",
+}
+
+// xsdGoTypes are the Go types of XML Schema built-in types, other built-in types being strings.
+// Decimals and unbounded integers are json.Number to keep their precision.
+var xsdGoTypes = map[string]string{
+	"boolean":            "bool",
+	"long":               "int64",
+	"int":                "int32",
+	"short":              "int16",
+	"byte":               "int8",
+	"unsignedLong":       "uint64",
+	"unsignedInt":        "uint32",
+	"unsignedShort":      "uint16",
+	"unsignedByte":       "uint8",
+	"integer":            "json.Number",
+	"nonNegativeInteger": "json.Number",
+	"positiveInteger":    "json.Number",
+	"nonPositiveInteger": "json.Number",
+	"negativeInteger":    "json.Number",
+	"decimal":            "json.Number",
+	"float":              "float32",
+	"double":             "float64",
+}
+
+// NewGenerator returns a new generator for a package.
+func NewGenerator(pkg string) *Generator {
+	return &Generator{Package: pkg}
+}
+
+// Analyzer returns the formatted Go code of the structs of the analyzed documents, one for each root element.
+// Types are the inferred ones, elements without text being strings.
+func (g *Generator) Analyzer(a *Analyzer) ([]byte, error) {
+	g.reset()
+	for _, info := range a.Paths() {
+		if info.parent == &a.root {
+			g.infoStruct(info, "")
+		}
+	}
+	return g.code()
+}
+
+// Schema returns the formatted Go code of the structs of an XML Schema, one for each global element with attributes
+// or child elements, using the decoder namespaces options to name elements.
+func (g *Generator) Schema(s *Schema, x *Decoder) ([]byte, error) {
+	err := x.init()
+	if err != nil {
+		return nil, err
+	}
+	x.raw = false
+	x.setConvention()
+	g.reset()
+	gen := &jsonSchemaGen{s: s, x: x}
+	for _, name := range s.rootNames() {
+		decl := s.elements[name]
+		e := s.element(decl)
+		if e.content == contentElements || e.content == contentMixed || len(e.attrs) > 0 {
+			g.schemaStruct(gen, e, gen.name(decl.ns, name), "")
+		}
+	}
+	return g.code()
+}
+
+func (g *Generator) reset() {
+	g.structs = nil
+	g.names = make(map[string]bool)
+	g.schemaTypes = make(map[*schemaElement]string)
+}
+
+// infoStruct adds the struct of an analyzed element and its children, returning its name.
+func (g *Generator) infoStruct(info *PathInfo, parent string) string {
+	st := g.newStruct(info.Name, parent)
+	for _, child := range info.children {
+		if child.Attribute {
+			st.addField(child.Name, goType(child.Type), localName(child.Name)+",attr", DefaultAttrPrefix+child.Name)
+		}
+	}
+	if info.Type != "" {
+		st.addField("Text", goType(info.Type), ",chardata", DefaultTextKey)
+	}
+	for _, child := range info.children {
+		if child.Attribute {
+			continue
+		}
+		var typ string
+		if child.objects > 0 {
+			typ = g.infoStruct(child, st.name)
+			if child.Optional && !child.Repeated {
+				typ = "*" + typ
+			}
+		} else {
+			typ = goType(child.Type)
+		}
+		if child.Repeated {
+			typ = "[]" + typ
+		}
+		st.addField(child.Name, typ, localName(child.Name), child.Name)
+	}
+	return st.name
+}
+
+// schemaStruct adds the struct of a schema element and its children, returning its name.
+func (g *Generator) schemaStruct(gen *jsonSchemaGen, e *schemaElement, name string, parent string) string {
+	if typ, ok := g.schemaTypes[e]; ok {
+		return typ
+	}
+	st := g.newStruct(name, parent)
+	g.schemaTypes[e] = st.name
+	if gen.x.conv.Attributes {
+		for _, a := range e.attrs {
+			st.addField(a.name, xsdGoType(a.simple), a.name+",attr", DefaultAttrPrefix+a.name)
+		}
+	}
+	switch e.content {
+	case contentSimple:
+		st.addField("Text", xsdGoType(e.simple), ",chardata", DefaultTextKey)
+	case contentMixed:
+		st.addField("Text", "string", ",chardata", DefaultTextKey)
+	}
+	for _, childName := range e.order {
+		child := e.children[childName]
+		decl := gen.s.element(child.decl)
+		jsonName := gen.name(child.decl.ns, childName)
+		var typ string
+		switch {
+		case decl.content == contentAny:
+			typ = "any"
+		case decl.content == contentElements || decl.content == contentMixed || (len(decl.attrs) > 0 && gen.x.conv.Attributes):
+			typ = g.schemaStruct(gen, decl, jsonName, st.name)
+			if child.min == 0 && !child.list {
+				typ = "*" + typ
+			}
+		default:
+			typ = xsdGoType(decl.simple)
+		}
+		if child.list {
+			typ = "[]" + typ
+		}
+		st.addField(jsonName, typ, childName, jsonName)
+	}
+	return st.name
+}
+
+// newStruct adds a struct for an element, named after the element, or after its parent struct and the element
+// if the name is already used.
+func (g *Generator) newStruct(element string, parent string) *goStruct {
+	name := goName(element)
+	if g.names[name] {
+		name = parent + name
+	}
+	for i := 2; g.names[name]; i++ {
+		name = fmt.Sprintf("%s%d", goName(element), i)
+	}
+	g.names[name] = true
+	st := &goStruct{name: name, names: make(map[string]bool)}
+	if parent == "" {
+		st.xmlName = localName(element)
+	}
+	g.structs = append(g.structs, st)
+	return st
+}
+
+// addField adds a field named after an element or attribute, adding a number to the name if it is already used.
+func (st *goStruct) addField(name string, typ string, xmlTag string, xqmlTag string) {
+	fieldName := goName(name)
+	if fieldName == "XMLName" {
+		fieldName = "XMLName_"
+	}
+	base := fieldName
+	for i := 2; st.names[fieldName]; i++ {
+		fieldName = fmt.Sprintf("%s%d", base, i)
+	}
+	st.names[fieldName] = true
+	st.fields = append(st.fields, &goField{name: fieldName, typ: typ, xml: xmlTag, xqml: xqmlTag})
+}
+
+// code returns the formatted code of the generated structs.
+func (g *Generator) code() ([]byte, error) {
+	var b bytes.Buffer
+	pkg := g.Package
+	if pkg == "" {
+		pkg = "main"
+	}
+	fmt.Fprintf(&b, "// Code generated by xqml. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	var imports []string
+	for _, st := range g.structs {
+		for _, f := range st.fields {
+			if strings.Contains(f.typ, "json.Number") {
+				imports = append(imports, "encoding/json")
+				break
+			}
+		}
+		if len(imports) > 0 {
+			break
+		}
+	}
+	if len(g.structs) > 0 && g.structs[0].xmlName != "" {
+		imports = append(imports, "encoding/xml")
+	}
+	if len(imports) == 1 {
+		fmt.Fprintf(&b, "import %q\n\n", imports[0])
+	} else if len(imports) > 1 {
+		b.WriteString("import (\n")
+		for _, imp := range imports {
+			fmt.Fprintf(&b, "%q\n", imp)
+		}
+		b.WriteString(")\n\n")
+	}
+	for _, st := range g.structs {
+		fmt.Fprintf(&b, "type %s struct {\n", st.name)
+		if st.xmlName != "" {
+			fmt.Fprintf(&b, "XMLName xml.Name `xml:%q xqml:\"-\"`\n", st.xmlName)
+		}
+		for _, f := range st.fields {
+			fmt.Fprintf(&b, "%s %s `xml:%q xqml:%q`\n", f.name, f.typ, f.xml, f.xqml)
+		}
+		b.WriteString("}\n\n")
+	}
+	return format.Source(b.Bytes())
+}
+
+// goType returns the Go type of a cast type, elements without text being strings.
+func goType(typ string) string {
+	if t, ok := goTypes[typ]; ok {
+		return t
+	}
+	return "string"
+}
+
+// xsdGoType returns the Go type of a simple type.
+func xsdGoType(t *simpleType) string {
+	if t == nil {
+		return "string"
+	}
+	if typ, ok := xsdGoTypes[t.builtin]; ok {
+		return typ
+	}
+	return "string"
+}
+
+// goName returns an exported Go name for an element or attribute name, like "ItemId" for "item-id" or "x:item_id".
+func goName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range localName(name) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	s := b.String()
+	if r, _ := utf8.DecodeRuneInString(s); !unicode.IsLetter(r) {
+		s = "X" + s
+	}
+	return s
+}
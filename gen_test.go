@@ -0,0 +1,147 @@
+package xqml
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_GeneratorAnalyzer(t *testing.T) {
+	a := NewAnalyzer()
+	err := a.Analyze(NewDecoder(strings.NewReader(`<feed version="2">
+		<title lang="en">News</title>
+		<item id="1"><name>a</name><price>1.5</price></item>
+		<item id="2"><name>b</name><item-tag>x</item-tag><item-tag>y</item-tag><title>t</title></item>
+		<empty/>
+	</feed>`)))
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	testGenerator(t, NewGenerator("feed").Analyzer, a, `// Code generated by xqml. DO NOT EDIT.
+
+package feed
+
+import "encoding/xml"
+
+type Feed struct {
+	XMLName xml.Name `+"`"+`xml:"feed" xqml:"-"`+"`"+`
+	Version int64    `+"`"+`xml:"version,attr" xqml:"@version"`+"`"+`
+	Title   Title    `+"`"+`xml:"title" xqml:"title"`+"`"+`
+	Item    []Item   `+"`"+`xml:"item" xqml:"item"`+"`"+`
+	Empty   string   `+"`"+`xml:"empty" xqml:"empty"`+"`"+`
+}
+
+type Title struct {
+	Lang string `+"`"+`xml:"lang,attr" xqml:"@lang"`+"`"+`
+	Text string `+"`"+`xml:",chardata" xqml:"#text"`+"`"+`
+}
+
+type Item struct {
+	Id      int64    `+"`"+`xml:"id,attr" xqml:"@id"`+"`"+`
+	Name    string   `+"`"+`xml:"name" xqml:"name"`+"`"+`
+	Price   float64  `+"`"+`xml:"price" xqml:"price"`+"`"+`
+	ItemTag []string `+"`"+`xml:"item-tag" xqml:"item-tag"`+"`"+`
+	Title   string   `+"`"+`xml:"title" xqml:"title"`+"`"+`
+}
+`)
+}
+
+func Test_GeneratorNumbers(t *testing.T) {
+	a := NewAnalyzer()
+	err := a.Analyze(NewDecoder(strings.NewReader(`<r><u>18446744073709551615</u><n>-1</n><n>18446744073709551615</n></r>`)))
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	testGenerator(t, NewGenerator("").Analyzer, a, `// Code generated by xqml. DO NOT EDIT.
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+)
+
+type R struct {
+	XMLName xml.Name      `+"`"+`xml:"r" xqml:"-"`+"`"+`
+	U       uint64        `+"`"+`xml:"u" xqml:"u"`+"`"+`
+	N       []json.Number `+"`"+`xml:"n" xqml:"n"`+"`"+`
+}
+`)
+	// decimals keep their precision
+	schema, err := LoadSchema(strings.NewReader(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="r">
+    <xs:complexType>
+      <xs:attribute name="d" type="xs:decimal"/>
+      <xs:attribute name="i" type="xs:integer"/>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`))
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	testGenerator(t, func(s *Schema) ([]byte, error) { return NewGenerator("").Schema(s, NewDecoder(nil)) }, schema, `// Code generated by xqml. DO NOT EDIT.
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+)
+
+type R struct {
+	XMLName xml.Name    `+"`"+`xml:"r" xqml:"-"`+"`"+`
+	D       json.Number `+"`"+`xml:"d,attr" xqml:"@d"`+"`"+`
+	I       json.Number `+"`"+`xml:"i,attr" xqml:"@i"`+"`"+`
+}
+`)
+}
+
+func Test_GeneratorSchema(t *testing.T) {
+	schema, err := LoadSchema(strings.NewReader(testJsonXsd))
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	x := NewDecoder(nil)
+	testGenerator(t, func(s *Schema) ([]byte, error) { return NewGenerator("").Schema(s, x) }, schema, `// Code generated by xqml. DO NOT EDIT.
+
+package main
+
+import "encoding/xml"
+
+type R struct {
+	XMLName xml.Name `+"`"+`xml:"r" xqml:"-"`+"`"+`
+	V       int32    `+"`"+`xml:"v,attr" xqml:"@v"`+"`"+`
+	E       []int32  `+"`"+`xml:"e" xqml:"e"`+"`"+`
+	S       *S       `+"`"+`xml:"s" xqml:"s"`+"`"+`
+	R       *R       `+"`"+`xml:"r" xqml:"r"`+"`"+`
+}
+
+type S struct {
+	A    bool   `+"`"+`xml:"a,attr" xqml:"@a"`+"`"+`
+	Text string `+"`"+`xml:",chardata" xqml:"#text"`+"`"+`
+}
+`)
+}
+
+func Test_GoName(t *testing.T) {
+	for name, expected := range map[string]string{"item": "Item", "item-id": "ItemId", "x:item_id": "ItemId", "1st": "X1st", "été": "Été"} {
+		if res := goName(name); res != expected {
+			t.Errorf("ERROR: received %s for %s", res, name)
+		}
+	}
+}
+
+func testGenerator[T any](t *testing.T, gen func(T) ([]byte, error), v T, expected string) {
+	t.Logf("")
+	code, err := gen(v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	if string(code) != expected {
+		t.Errorf("ERROR: received %s", code)
+	}
+}
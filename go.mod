@@ -1,3 +1,8 @@
 module github.com/momiji/xqml
 
-go 1.18
+go 1.19
+
+require (
+	golang.org/x/net v0.33.0
+	golang.org/x/text v0.21.0
+)
@@ -0,0 +1,191 @@
+package xqml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"mime"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// htmlVoidElements are the HTML elements without end tag.
+var htmlVoidElements = map[string]bool{
+	"area":     true,
+	"base":     true,
+	"basefont": true,
+	"bgsound":  true,
+	"br":       true,
+	"col":      true,
+	"embed":    true,
+	"frame":    true,
+	"hr":       true,
+	"img":      true,
+	"input":    true,
+	"keygen":   true,
+	"link":     true,
+	"meta":     true,
+	"param":    true,
+	"source":   true,
+	"track":    true,
+	"wbr":      true,
+}
+
+// htmlReader returns the tokens of an HTML5 document, parsed with the HTML5 tree building rules, see Decoder.Html5.
+type htmlReader struct {
+	x      *Decoder
+	tokens []xml.Token
+	parsed bool
+}
+
+// Token returns the next token of the document, parsing the whole input on first call.
+func (r *htmlReader) Token() (xml.Token, error) {
+	if !r.parsed {
+		r.parsed = true
+		input, err := r.reader()
+		if err != nil {
+			return nil, err
+		}
+		doc, err := html.Parse(input)
+		if err != nil {
+			return nil, err
+		}
+		r.addNode(doc)
+	}
+	if len(r.tokens) == 0 {
+		return nil, io.EOF
+	}
+	token := r.tokens[0]
+	r.tokens = r.tokens[1:]
+	return token, nil
+}
+
+// reader returns the input converted to UTF-8, its encoding being detected from its BOM or <meta> charset,
+// then converted with the decoder CharsetReader. Without BOM nor <meta> charset, the input is UTF-8.
+func (r *htmlReader) reader() (io.Reader, error) {
+	input := bufio.NewReader(r.x.input)
+	peek, _ := input.Peek(1024)
+	name := ""
+	for _, bom := range htmlBoms {
+		if bytes.HasPrefix(peek, []byte(bom.bom)) {
+			name = bom.charset
+			_, _ = input.Discard(len(bom.bom))
+			break
+		}
+	}
+	if name == "" {
+		name = htmlMetaCharset(peek)
+	}
+	if isUTF8(name) || r.x.CharsetReader == nil {
+		return input, nil
+	}
+	return r.x.CharsetReader(name, input)
+}
+
+// htmlBoms are the byte order marks of HTML documents.
+var htmlBoms = []struct{ bom, charset string }{
+	{"\xef\xbb\xbf", "utf-8"},
+	{"\xfe\xff", "utf-16be"},
+	{"\xff\xfe", "utf-16le"},
+}
+
+// htmlMetaCharset returns the charset of a <meta charset> or <meta http-equiv="Content-Type"> element found in the
+// beginning of a document, or "" if there is none. UTF-16 charsets are UTF-8, as such documents would start with a BOM.
+func htmlMetaCharset(peek []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(peek))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != "meta" {
+				continue
+			}
+			var cs, httpEquiv, content string
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = z.TagAttr()
+				switch string(key) {
+				case "charset":
+					cs = string(value)
+				case "http-equiv":
+					httpEquiv = strings.ToLower(string(value))
+				case "content":
+					content = string(value)
+				}
+			}
+			if cs == "" && httpEquiv == "content-type" {
+				if _, params, err := mime.ParseMediaType(content); err == nil {
+					cs = params["charset"]
+				}
+			}
+			if cs = strings.TrimSpace(cs); cs != "" {
+				if strings.HasPrefix(strings.ToLower(cs), "utf-16") {
+					return "utf-8"
+				}
+				return cs
+			}
+		}
+	}
+}
+
+// addNode adds the tokens of a node and its children.
+func (r *htmlReader) addNode(n *html.Node) {
+	switch n.Type {
+	case html.DoctypeNode:
+		r.tokens = append(r.tokens, xml.Directive("DOCTYPE "+n.Data))
+		return
+	case html.CommentNode:
+		r.tokens = append(r.tokens, xml.Comment(n.Data))
+		return
+	case html.TextNode:
+		r.tokens = append(r.tokens, xml.CharData(n.Data))
+		return
+	case html.ElementNode:
+		start := xml.StartElement{Name: xml.Name{Local: n.Data}}
+		for _, a := range n.Attr {
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Space: a.Namespace, Local: a.Key}, Value: a.Val})
+		}
+		r.tokens = append(r.tokens, start)
+		defer func() { r.tokens = append(r.tokens, start.End()) }()
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.addNode(c)
+	}
+}
+
+// htmlWriter writes the output of the encoder, dropping the end tags of void elements, see Encoder.Html.
+type htmlWriter struct {
+	writer  io.Writer
+	discard bool
+}
+
+func (w *htmlWriter) Write(p []byte) (int, error) {
+	if w.discard {
+		return len(p), nil
+	}
+	return w.writer.Write(p)
+}
+
+// isVoid returns true if an element is an HTML void element written without end tag.
+func (x *Encoder) isVoid(end xml.EndElement) bool {
+	return x.html != nil && htmlVoidElements[end.Name.Local]
+}
+
+// endVoid ends a void element, encoding its end tag to keep the encoder state, but without writing it.
+func (x *Encoder) endVoid(end xml.EndElement) error {
+	err := x.encoder.Flush()
+	if err != nil {
+		return err
+	}
+	x.html.discard = true
+	defer func() { x.html.discard = false }()
+	err = x.encoder.EncodeToken(end)
+	if err != nil {
+		return err
+	}
+	return x.encoder.Flush()
+}
@@ -0,0 +1,59 @@
+package xqml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_Html5(t *testing.T) {
+	// implicit elements
+	testHtml5(t, `<p>a`, `{"html":{"body":{"p":"a"},"head":null}}`)
+	testHtml5(t, `<!DOCTYPE html><title>t</title><p>a<p>b`, `{"html":{"body":{"p":["a","b"]},"head":{"title":"t"}}}`)
+	testHtml5(t, `<table><tr><td>1<td>2</table>`, `{"html":{"body":{"table":{"tbody":{"tr":{"td":[1,2]}}}},"head":null}}`)
+	testHtml5(t, `<ul><li>a<li>b</ul>`, `{"html":{"body":{"ul":{"li":["a","b"]}},"head":null}}`)
+	// unquoted attributes, void elements and stray end tags
+	testHtml5(t, `<div class=x id=1>a<br>b</span></div>`, `{"html":{"body":{"div":{"#text":"a b","@class":"x","@id":"1","br":null}},"head":null}}`)
+	testHtml5(t, `<p>a &amp; b&nbsp;&copy;</p>`, `{"html":{"body":{"p":"a \u0026 b`+"\u00a0"+`©"},"head":null}}`)
+	// encoding
+	testHtml5(t, "<meta charset=\"windows-1252\"><p>caf\xe9</p>", `{"html":{"body":{"p":"café"},"head":{"meta":{"@charset":"windows-1252"}}}}`)
+	testHtml5(t, "<meta http-equiv=Content-Type content=\"text/html; charset=ISO-8859-1\"><p>caf\xe9</p>", `{"html":{"body":{"p":"café"},"head":{"meta":{"@content":"text/html; charset=ISO-8859-1","@http-equiv":"Content-Type"}}}}`)
+	testHtml5(t, "<p>"+strings.Repeat("a", 1100)+"</p><p>café</p>", `{"html":{"body":{"p":["`+strings.Repeat("a", 1100)+`","café"]},"head":null}}`)
+	testHtml5(t, "\xef\xbb\xbf<p>café</p>", `{"html":{"body":{"p":"café"},"head":null}}`)
+	testHtml5(t, "\xff\xfe<\x00p\x00>\x00\xe9\x00", `{"html":{"body":{"p":"é"},"head":null}}`)
+	// encoder
+	testHtmlEncoder(t, "", map[string]any{"p": map[string]any{"#text": "a", "br": nil, "img": map[string]any{"@src": "x.png", "#text": "ignored"}}}, `<p>a<br><img src="x.png"></p>`)
+	testHtmlEncoder(t, "  ", map[string]any{"div": map[string]any{"br": "", "p": []any{"a", "b"}}}, "<div>\n  <br>\n  <p>a</p>\n  <p>b</p>\n</div>")
+}
+
+func testHtml5(t *testing.T, src string, rjson string) {
+	t.Logf("")
+	t.Logf("html => json: %q => %s\n", src, rjson)
+	x := NewDecoder(strings.NewReader(src))
+	x.Html5 = true
+	var v any
+	err := x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	res := Stringify(v)
+	if res != rjson {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
+
+func testHtmlEncoder(t *testing.T, indent string, value any, rhtml string) {
+	t.Logf("")
+	t.Logf("json => html: %s => %q\n", Stringify(value), rhtml)
+	var b bytes.Buffer
+	x := NewEncoder(&b)
+	x.Html = true
+	x.Indent = indent
+	err := x.Encode(value)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res := b.String(); res != rhtml {
+		t.Errorf("ERROR: received %q\n", res)
+	}
+}
@@ -0,0 +1,338 @@
+package xqml
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// jsonSchemaGen generates the JSON Schema of decoded documents from an XML Schema.
+type jsonSchemaGen struct {
+	s *Schema
+	x *Decoder
+	// stack are the elements being generated, to stop on recursive elements
+	stack map[*schemaElement]bool
+}
+
+// JSONSchema returns a JSON Schema of the values returned by Decode into a *any, for documents valid against the schema,
+// using the decoder settings: Attributes, namespaces options, the attributes and text keys convention,
+// ForceList, CastRules, Cast, CastAttrs and Mixed.
+//
+// When the decoder Schema is set, lists and types follow the schema. Otherwise, elements which can be repeated
+// and are not forced to lists are described with oneOf a single value or a list.
+// Elements are matched with ForceList and CastRules by their path, so a Caster makes values of any type.
+// Recursive elements are described with an empty schema, and namespace declarations attributes are not described.
+// With Mixed, the text of elements with mixed content is described in "#mixed" segments, instead of the text key.
+func (s *Schema) JSONSchema(x *Decoder) (*OrderedMap, error) {
+	err := x.init()
+	if err != nil {
+		return nil, err
+	}
+	x.raw = false
+	x.setConvention()
+	g := &jsonSchemaGen{s: s, x: x, stack: make(map[*schemaElement]bool)}
+	res := NewOrderedMap()
+	res.Set("$schema", JSONSchemaVersion)
+	var docs []any
+	for _, name := range s.rootNames() {
+		decl := s.elements[name]
+		jsonName := g.name(decl.ns, name)
+		doc := schemaOf("type", "object")
+		props := NewOrderedMap()
+		props.Set(jsonName, g.element(s.element(decl), jsonName))
+		doc.Set("properties", props)
+		doc.Set("required", []any{jsonName})
+		docs = append(docs, doc)
+	}
+	switch len(docs) {
+	case 0:
+	case 1:
+		for _, k := range docs[0].(*OrderedMap).Keys() {
+			v, _ := docs[0].(*OrderedMap).Get(k)
+			res.Set(k, v)
+		}
+	default:
+		res.Set("oneOf", docs)
+	}
+	return res, nil
+}
+
+// name returns the name of an element in decoded documents,
+// the namespaces prefixes being the ones of the schema when NsPrefixes is true.
+func (g *jsonSchemaGen) name(ns string, local string) string {
+	saved := g.x.ns
+	g.x.ns = g.s.prefixes
+	defer func() { g.x.ns = saved }()
+	return g.x.newName(&xml.Name{Space: ns, Local: local})
+}
+
+// element returns the schema of an element value, being a scalar, an object or null depending on its content.
+func (g *jsonSchemaGen) element(e *schemaElement, path string) any {
+	if g.stack[e] || e.content == contentAny || g.x.Caster != nil {
+		return NewOrderedMap()
+	}
+	g.stack[e] = true
+	defer delete(g.stack, e)
+	conv := g.x.conv
+	var alternatives []any
+	nillable := e.decl.Nillable == "true"
+	attrs, attrsRequired := g.attributes(e, path)
+	attrsPresent := len(attrsRequired) > 0
+	// schema of the text in objects, scalar values using their own schema as null can be added
+	text := g.text(e, path)
+	switch e.content {
+	case contentSimple:
+		if !attrsPresent {
+			if conv.TextObject {
+				alternatives = append(alternatives, g.object(e, path, nil, nil, text, false))
+			} else {
+				alternatives = append(alternatives, g.text(e, path))
+			}
+		}
+		if attrs != nil {
+			alternatives = append(alternatives, g.object(e, path, attrs, attrsRequired, text, false))
+		}
+		nillable = nillable || g.empty(e.simple)
+	case contentMixed:
+		if !attrsPresent {
+			if conv.TextObject {
+				alternatives = append(alternatives, g.object(e, path, nil, nil, text, false))
+			} else {
+				alternatives = append(alternatives, g.text(e, path))
+			}
+		}
+		alternatives = append(alternatives, g.object(e, path, attrs, attrsRequired, text, true))
+		nillable = nillable || !attrsPresent
+	default:
+		required := false
+		for _, child := range e.children {
+			required = required || child.min > 0
+		}
+		alternatives = append(alternatives, g.object(e, path, attrs, attrsRequired, nil, true))
+		nillable = nillable || (!attrsPresent && !required)
+	}
+	if nillable && !addNull(alternatives[0]) {
+		alternatives = append(alternatives, schemaOf("type", "null"))
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0]
+	}
+	return schemaOf("oneOf", alternatives)
+}
+
+// object returns the schema of an element decoded as an object, with its attributes, text and children.
+func (g *jsonSchemaGen) object(e *schemaElement, path string, attrs *OrderedMap, attrsRequired []any, text any, children bool) *OrderedMap {
+	conv := g.x.conv
+	s := schemaOf("type", "object")
+	props := NewOrderedMap()
+	var required []any
+	if attrs != nil {
+		if conv.AttrKey != "" {
+			group := schemaOf("type", "object")
+			group.Set("properties", attrs)
+			if len(attrsRequired) > 0 {
+				group.Set("required", attrsRequired)
+				required = append(required, conv.AttrKey)
+			}
+			props.Set(conv.AttrKey, group)
+		} else {
+			for _, k := range attrs.Keys() {
+				v, _ := attrs.Get(k)
+				props.Set(k, v)
+			}
+			required = append(required, attrsRequired...)
+		}
+	}
+	mixed := g.x.Mixed && e.content == contentMixed
+	// the text of mixed content is in "#mixed" segments
+	if text != nil && !(children && mixed) {
+		props.Set(conv.TextKey, text)
+		// the text is only missing from elements with attributes or children
+		if attrs == nil && !children {
+			required = append(required, conv.TextKey)
+		}
+	}
+	if children {
+		var segments *OrderedMap
+		if mixed {
+			segments = NewOrderedMap()
+			props.Set(MixedKey, mixedSchema(segments))
+		}
+		for _, name := range e.order {
+			child := e.children[name]
+			decl := g.s.element(child.decl)
+			jsonName := g.name(child.decl.ns, name)
+			props.Set(jsonName, g.child(decl, child, newPath(path, jsonName)))
+			if mixed {
+				segments.Set(jsonName, g.element(decl, newPath(path, jsonName)))
+			}
+			if child.min > 0 && !mixed {
+				required = append(required, jsonName)
+			}
+		}
+	}
+	s.Set("properties", props)
+	if len(required) > 0 {
+		s.Set("required", required)
+	}
+	return s
+}
+
+// mixedSchema returns the schema of a "#mixed" list of segments, being texts or single key objects of child elements,
+// the schemas of the child elements being set in elements.
+func mixedSchema(elements *OrderedMap) *OrderedMap {
+	element := schemaOf("type", "object")
+	element.Set("properties", elements)
+	element.Set("minProperties", 1)
+	element.Set("maxProperties", 1)
+	array := schemaOf("type", "array")
+	array.Set("items", schemaOf("anyOf", []any{schemaOf("type", "string"), element}))
+	return array
+}
+
+// child returns the schema of a child element, being a list if it is forced, or oneOf a value or a list if it can be repeated.
+func (g *jsonSchemaGen) child(e *schemaElement, child *schemaChild, path string) any {
+	name := path[strings.LastIndexByte(path, '.')+1:]
+	item := g.element(e, path)
+	array := schemaOf("type", "array")
+	array.Set("items", item)
+	forced := g.x.forceList[name] || g.x.forceList[path] || (g.x.Schema != nil && child.list)
+	if !forced && !child.list {
+		return item
+	}
+	if child.max > 0 {
+		array.Set("maxItems", child.max)
+	}
+	if forced || child.min > 1 {
+		if child.min > 1 {
+			array.Set("minItems", child.min)
+		}
+		return array
+	}
+	array.Set("minItems", 2)
+	// merge alternatives of the item, which are never arrays
+	if alternatives, ok := item.(*OrderedMap).Get("oneOf"); ok {
+		return schemaOf("oneOf", append(append([]any{}, alternatives.([]any)...), array))
+	}
+	// an empty item schema also matches arrays, so the single value must not be an array
+	if item.(*OrderedMap).Len() == 0 {
+		item = schemaOf("not", schemaOf("type", "array"))
+	}
+	return schemaOf("oneOf", []any{item, array})
+}
+
+// attributes returns the schemas of the attributes, or nil if there is none,
+// and the keys of the attributes always present, being required or having a default value added by the decoder Schema.
+func (g *jsonSchemaGen) attributes(e *schemaElement, path string) (*OrderedMap, []any) {
+	conv := g.x.conv
+	if !conv.Attributes || len(e.attrs) == 0 {
+		return nil, nil
+	}
+	props := NewOrderedMap()
+	var required []any
+	for _, a := range e.attrs {
+		var types []string
+		switch {
+		case g.x.castRules.find(path+".@"+a.name, "@"+a.name) != "":
+			types = jsonTypes(g.x.castRules.find(path+".@"+a.name, "@"+a.name), a.simple)
+		case g.x.Schema != nil && a.cast != "":
+			types = schemaTypes(a.cast, a.simple)
+		case g.x.Cast && g.x.CastAttrs:
+			types = jsonTypes(CastAuto, a.simple)
+		default:
+			types = []string{"string"}
+		}
+		key := conv.AttrPrefix + a.name
+		props.Set(key, typesSchema(types))
+		if a.required || (a.hasDefault && g.x.Schema != nil) {
+			required = append(required, key)
+		}
+	}
+	return props, required
+}
+
+// text returns the schema of the text of an element, according to cast rules, schema types or Cast heuristic.
+func (g *jsonSchemaGen) text(e *schemaElement, path string) any {
+	name := path[strings.LastIndexByte(path, '.')+1:]
+	var types []string
+	switch {
+	case g.x.castRules.find(path, name) != "":
+		types = jsonTypes(g.x.castRules.find(path, name), e.simple)
+	case e.content == contentMixed:
+		types = []string{"string"}
+	case g.x.Schema != nil && e.cast != "":
+		types = schemaTypes(e.cast, e.simple)
+	case g.x.Cast:
+		types = jsonTypes(CastAuto, e.simple)
+	default:
+		types = []string{"string"}
+	}
+	return typesSchema(types)
+}
+
+// empty returns true if an empty text is valid for a simple type, being decoded as null.
+func (g *jsonSchemaGen) empty(t *simpleType) bool {
+	return t == nil || g.s.validateValue(t, "") == ""
+}
+
+// jsonTypes returns the JSON types of a value cast to a type.
+// With CastAuto, the types are the ones the Cast heuristic returns for valid values of the simple type.
+func jsonTypes(typ string, t *simpleType) []string {
+	switch typ {
+	case CastString:
+		return []string{"string"}
+	case CastInt, CastUint:
+		return []string{"integer"}
+	case CastFloat, CastDecimal, CastNumber:
+		return []string{"number"}
+	case CastBool:
+		return []string{"boolean"}
+	}
+	switch t.castType() {
+	case CastInt, CastUint:
+		return []string{"integer"}
+	case CastDecimal, CastFloat:
+		return []string{"number"}
+	case CastBool:
+		return []string{"boolean", "integer"}
+	}
+	return []string{"string", "number", "boolean"}
+}
+
+// schemaTypes returns the JSON types of a value cast to its Schema cast type.
+// Floats also are strings, as infinities and NaN are kept unchanged.
+func schemaTypes(typ string, t *simpleType) []string {
+	if typ == CastFloat {
+		return []string{"number", "string"}
+	}
+	return jsonTypes(typ, t)
+}
+
+// addNull adds the null type to a scalar schema, returning false if it is an object schema.
+func addNull(s any) bool {
+	m := s.(*OrderedMap)
+	types, _ := m.Get("type")
+	switch types := types.(type) {
+	case string:
+		if types == "object" {
+			return false
+		}
+		m.Set("type", []any{types, "null"})
+	case []any:
+		m.Set("type", append(types, "null"))
+	default:
+		return false
+	}
+	return true
+}
+
+// typesSchema returns a schema for one or more JSON types.
+func typesSchema(types []string) *OrderedMap {
+	if len(types) == 1 {
+		return schemaOf("type", types[0])
+	}
+	list := make([]any, len(types))
+	for i, t := range types {
+		list[i] = t
+	}
+	return schemaOf("type", list)
+}
@@ -0,0 +1,264 @@
+package xqml
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+const testJsonXsd = `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="r">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="e" type="xs:int" maxOccurs="unbounded"/>
+        <xs:element name="s" minOccurs="0">
+          <xs:complexType>
+            <xs:simpleContent>
+              <xs:extension base="xs:string">
+                <xs:attribute name="a" type="xs:boolean"/>
+              </xs:extension>
+            </xs:simpleContent>
+          </xs:complexType>
+        </xs:element>
+        <xs:element ref="r" minOccurs="0"/>
+      </xs:sequence>
+      <xs:attribute name="v" type="xs:int" default="1"/>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`
+
+func Test_SchemaJSONSchema(t *testing.T) {
+	schema, err := LoadSchema(strings.NewReader(testJsonXsd))
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	// single or repeated elements
+	testJSONSchema(t, schema, func(x *Decoder) {},
+		`{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{"r":{"type":"object","properties":{"@v":{"type":"string"},"e":{"oneOf":[{"type":"integer"},{"type":"array","items":{"type":"integer"},"minItems":2}]},"s":{"oneOf":[{"type":["string","number","boolean","null"]},{"type":"object","properties":{"@a":{"type":"string"},"#text":{"type":["string","number","boolean"]}}}]},"r":{}},"required":["e"]}},"required":["r"]}`)
+	// forced lists and schema types
+	testJSONSchema(t, schema, func(x *Decoder) { x.Schema = schema },
+		`{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{"r":{"type":"object","properties":{"@v":{"type":"integer"},"e":{"type":"array","items":{"type":"integer"}},"s":{"oneOf":[{"type":["string","null"]},{"type":"object","properties":{"@a":{"type":"boolean"},"#text":{"type":"string"}}}]},"r":{}},"required":["@v","e"]}},"required":["r"]}`)
+	// convention, force list and cast rules
+	testJSONSchema(t, schema, func(x *Decoder) {
+		x.SetConvention(Abdera)
+		x.ForceList = []string{"e"}
+		x.CastRules = []string{"r.s=int"}
+		x.Cast = false
+	},
+		`{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{"r":{"type":"object","properties":{"attributes":{"type":"object","properties":{"v":{"type":"string"}}},"e":{"type":"array","items":{"type":"string"}},"s":{"oneOf":[{"type":["integer","null"]},{"type":"object","properties":{"attributes":{"type":"object","properties":{"a":{"type":"string"}}},"children":{"type":"integer"}}}]},"r":{}},"required":["e"]}},"required":["r"]}`)
+	// no attributes
+	testJSONSchema(t, schema, func(x *Decoder) { x.Attributes = false; x.Cast = false },
+		`{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{"r":{"type":"object","properties":{"e":{"oneOf":[{"type":"string"},{"type":"array","items":{"type":"string"},"minItems":2}]},"s":{"type":["string","null"]},"r":{}},"required":["e"]}},"required":["r"]}`)
+}
+
+func Test_SchemaJSONSchemaAny(t *testing.T) {
+	schema, err := LoadSchema(strings.NewReader(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="r">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="e" maxOccurs="unbounded"/>
+      </xs:sequence>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`))
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	// untyped repeated element, a list not matching the single value
+	testJSONSchema(t, schema, func(x *Decoder) {},
+		`{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{"r":{"type":"object","properties":{"e":{"oneOf":[{"not":{"type":"array"}},{"type":"array","items":{},"minItems":2}]}},"required":["e"]}},"required":["r"]}`)
+	// caster
+	testJSONSchema(t, schema, func(x *Decoder) { x.Caster = CasterFunc(func(path string, s string) (any, error) { return s, nil }) },
+		`{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{"r":{}},"required":["r"]}`)
+}
+
+func Test_SchemaJSONSchemaMixed(t *testing.T) {
+	schema, err := LoadSchema(strings.NewReader(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="p">
+    <xs:complexType mixed="true">
+      <xs:sequence>
+        <xs:element name="b" minOccurs="0" maxOccurs="unbounded">
+          <xs:complexType mixed="true">
+            <xs:sequence>
+              <xs:element name="i" type="xs:string" minOccurs="0"/>
+            </xs:sequence>
+          </xs:complexType>
+        </xs:element>
+        <xs:element name="n" type="xs:int" minOccurs="0"/>
+      </xs:sequence>
+      <xs:attribute name="a" type="xs:int"/>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`))
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	testJSONSchema(t, schema, func(x *Decoder) { x.Mixed = true },
+		`{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{"p":{"oneOf":[{"type":["string","null"]},{"type":"object","properties":{"@a":{"type":"string"},"#mixed":{"type":"array","items":{"anyOf":[{"type":"string"},{"type":"object","properties":{"b":{"oneOf":[{"type":["string","null"]},{"type":"object","properties":{"#mixed":{"type":"array","items":{"anyOf":[{"type":"string"},{"type":"object","properties":{"i":{"type":["string","number","boolean","null"]}},"minProperties":1,"maxProperties":1}]}},"i":{"type":["string","number","boolean","null"]}}}]},"n":{"type":"integer"}},"minProperties":1,"maxProperties":1}]}},"b":{"oneOf":[{"type":["string","null"]},{"type":"object","properties":{"#mixed":{"type":"array","items":{"anyOf":[{"type":"string"},{"type":"object","properties":{"i":{"type":["string","number","boolean","null"]}},"minProperties":1,"maxProperties":1}]}},"i":{"type":["string","number","boolean","null"]}}},{"type":"array","items":{"oneOf":[{"type":["string","null"]},{"type":"object","properties":{"#mixed":{"type":"array","items":{"anyOf":[{"type":"string"},{"type":"object","properties":{"i":{"type":["string","number","boolean","null"]}},"minProperties":1,"maxProperties":1}]}},"i":{"type":["string","number","boolean","null"]}}}]},"minItems":2}]},"n":{"type":"integer"}}}]}},"required":["p"]}`)
+	// decoded documents are valid against the schemas generated from the XML Schema and by an analyzer
+	docs := []string{
+		`<p a="1">Hello <b>big <i>wide</i></b> <n>2</n>!</p>`,
+		`<p>text</p>`,
+		`<p><b>x</b><b>y<i>z</i></b></p>`,
+		`<p a="1">only</p>`,
+		`<p/>`,
+	}
+	a := NewAnalyzer()
+	for _, src := range docs {
+		x := NewDecoder(strings.NewReader(src))
+		x.Mixed = true
+		if err := a.Analyze(x); err != nil {
+			t.Errorf("ERROR: %v", err)
+		}
+	}
+	x := NewDecoder(strings.NewReader(""))
+	x.Mixed = true
+	s, err := schema.JSONSchema(x)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	for _, src := range docs {
+		x := NewDecoder(strings.NewReader(src))
+		x.Mixed = true
+		testJSONSchemaValue(t, s, x)
+		x = NewDecoder(strings.NewReader(src))
+		x.Mixed = true
+		a.Config().Apply(x)
+		testJSONSchemaValue(t, a.JSONSchema(), x)
+	}
+	// segments are single key objects
+	if err := validJSON(jsonValue(s), jsonValue(map[string]any{"p": map[string]any{MixedKey: []any{"a", map[string]any{"b": "x", "n": 1}}}}), ""); err == nil {
+		t.Errorf("ERROR: expected error")
+	}
+}
+
+// testJSONSchemaValue checks the value decoded by a decoder is valid against a JSON schema.
+func testJSONSchemaValue(t *testing.T, schema *OrderedMap, x *Decoder) {
+	var v any
+	err := x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	if err := validJSON(jsonValue(schema), jsonValue(v), ""); err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+}
+
+// jsonValue returns a value as decoded from its JSON encoding.
+func jsonValue(v any) any {
+	b, _ := json.Marshal(v)
+	var res any
+	_ = json.Unmarshal(b, &res)
+	return res
+}
+
+// validJSON returns an error if a JSON value is not valid against a JSON schema,
+// only the keywords of generated schemas being supported.
+func validJSON(schema any, value any, path string) error {
+	s := schema.(map[string]any)
+	if t, ok := s["type"]; ok {
+		types, isList := t.([]any)
+		if !isList {
+			types = []any{t}
+		}
+		valid := false
+		for _, typ := range types {
+			valid = valid || isJSONType(typ.(string), value)
+		}
+		if !valid {
+			return fmt.Errorf("%s: %v is not of type %v", path, value, t)
+		}
+	}
+	for _, k := range []string{"anyOf", "oneOf"} {
+		if alternatives, ok := s[k].([]any); ok {
+			n := 0
+			for _, alternative := range alternatives {
+				if validJSON(alternative, value, path) == nil {
+					n++
+				}
+			}
+			if n == 0 || (k == "oneOf" && n > 1) {
+				return fmt.Errorf("%s: %v matches %d schemas of %s", path, value, n, k)
+			}
+		}
+	}
+	if not, ok := s["not"]; ok && validJSON(not, value, path) == nil {
+		return fmt.Errorf("%s: %v matches not schema", path, value)
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		props, _ := s["properties"].(map[string]any)
+		for k, p := range props {
+			if e, ok := v[k]; ok {
+				if err := validJSON(p, e, path+"."+k); err != nil {
+					return err
+				}
+			}
+		}
+		required, _ := s["required"].([]any)
+		for _, k := range required {
+			if _, ok := v[k.(string)]; !ok {
+				return fmt.Errorf("%s: missing %s", path, k)
+			}
+		}
+		if min, ok := s["minProperties"].(float64); ok && float64(len(v)) < min {
+			return fmt.Errorf("%s: less than %v properties", path, min)
+		}
+		if max, ok := s["maxProperties"].(float64); ok && float64(len(v)) > max {
+			return fmt.Errorf("%s: more than %v properties", path, max)
+		}
+	case []any:
+		if items, ok := s["items"]; ok {
+			for i, e := range v {
+				if err := validJSON(items, e, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+		if min, ok := s["minItems"].(float64); ok && float64(len(v)) < min {
+			return fmt.Errorf("%s: less than %v items", path, min)
+		}
+		if max, ok := s["maxItems"].(float64); ok && float64(len(v)) > max {
+			return fmt.Errorf("%s: more than %v items", path, max)
+		}
+	}
+	return nil
+}
+
+// isJSONType returns true if a JSON value is of a JSON Schema type.
+func isJSONType(typ string, value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return typ == "null"
+	case bool:
+		return typ == "boolean"
+	case string:
+		return typ == "string"
+	case float64:
+		return typ == "number" || (typ == "integer" && v == math.Trunc(v))
+	case map[string]any:
+		return typ == "object"
+	case []any:
+		return typ == "array"
+	}
+	return false
+}
+
+func testJSONSchema(t *testing.T, schema *Schema, setup func(x *Decoder), rjson string) {
+	t.Logf("")
+	x := NewDecoder(strings.NewReader(""))
+	setup(x)
+	s, err := schema.JSONSchema(x)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	testAnalyzer(t, s, rjson)
+}
@@ -0,0 +1,200 @@
+package xqml
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// generic converts a Go value to its generic representation, made of map[string]any, []any and scalar values,
+// so that it produces the same XML as the equivalent generic value.
+// Structs are converted using xqml struct tags: "x" for an element, "@x" for an attribute,
+// "#text" for the element text and "a.b" for a path.
+// Generic values are returned unchanged, only maps and slices containing other values being copied.
+func (x *Encoder) generic(value any) (any, error) {
+	if isGeneric(value) {
+		return value, nil
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			g, err := x.generic(e)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = g
+		}
+		return m, nil
+	case []any:
+		s := make([]any, len(v))
+		for i, e := range v {
+			g, err := x.generic(e)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = g
+		}
+		return s, nil
+	case *OrderedMap:
+		m := NewOrderedMap()
+		for _, k := range v.Keys() {
+			e, _ := v.Get(k)
+			g, err := x.generic(e)
+			if err != nil {
+				return nil, err
+			}
+			m.Set(k, g)
+		}
+		return m, nil
+	}
+	return x.genericValue(reflect.ValueOf(value))
+}
+
+// isGeneric returns true if a value is only made of map[string]any, *OrderedMap, []any and scalar values.
+func isGeneric(value any) bool {
+	switch v := value.(type) {
+	case nil, string, bool, int, int64, uint64, float64:
+		return true
+	case map[string]any:
+		for _, e := range v {
+			if !isGeneric(e) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		for _, e := range v {
+			if !isGeneric(e) {
+				return false
+			}
+		}
+		return true
+	case *OrderedMap:
+		for _, k := range v.Keys() {
+			if e, _ := v.Get(k); !isGeneric(e) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (x *Encoder) genericValue(rv reflect.Value) (any, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	// use text marshaler when available
+	if rv.Type().Implements(textMarshalerType) {
+		if rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil, nil
+		}
+		text, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return string(text), nil
+	}
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return x.generic(rv.Elem().Interface())
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		m := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			g, err := x.generic(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprintf("%v", iter.Key().Interface())] = g
+		}
+		return m, nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(rv.Bytes()), nil
+		}
+		s := make([]any, rv.Len())
+		for i := range s {
+			g, err := x.generic(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			s[i] = g
+		}
+		return s, nil
+	case reflect.Struct:
+		return x.genericStruct(rv)
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return rv.Interface(), nil
+	}
+	return nil, fmt.Errorf("cannot encode value of type %s", rv.Type())
+}
+
+func (x *Encoder) genericStruct(rv reflect.Value) (any, error) {
+	m := make(map[string]any)
+	for _, f := range typeFields(rv.Type()) {
+		fv, ok := fieldValue(rv, f.index)
+		if !ok || (f.omitEmpty && fv.IsZero()) {
+			continue
+		}
+		g, err := x.genericValue(fv)
+		if err != nil {
+			return nil, err
+		}
+		// set value at path, creating intermediate elements
+		path := x.fieldPath(f.path)
+		curr := m
+		for _, name := range path[:len(path)-1] {
+			next, isMap := curr[name].(map[string]any)
+			if !isMap {
+				next = make(map[string]any)
+				curr[name] = next
+			}
+			curr = next
+		}
+		curr[path[len(path)-1]] = g
+	}
+	return m, nil
+}
+
+// fieldPath converts "@x" and "#text" tag names of a field path to the encoder convention.
+func (x *Encoder) fieldPath(path []string) []string {
+	last := path[len(path)-1]
+	if strings.HasPrefix(last, DefaultAttrPrefix) {
+		res := append([]string{}, path[:len(path)-1]...)
+		if x.AttrKey != "" {
+			return append(res, x.AttrKey, last[len(DefaultAttrPrefix):])
+		}
+		return append(res, x.AttrPrefix+last[len(DefaultAttrPrefix):])
+	}
+	if last == DefaultTextKey {
+		res := append([]string{}, path[:len(path)-1]...)
+		return append(res, x.TextKey)
+	}
+	return path
+}
+
+// fieldValue returns the nested field of a struct, or false if an embedded pointer is nil.
+func fieldValue(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, idx := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				return reflect.Value{}, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(idx)
+	}
+	return rv, true
+}
@@ -0,0 +1,100 @@
+package xqml
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testEntry struct {
+	Id    int      `xqml:"@id"`
+	Name  string   `xqml:"name"`
+	Tags  []string `xqml:"tags.tag"`
+	Price float64  `xqml:"price,omitempty"`
+}
+
+type testBase struct {
+	Version string `xqml:"@version"`
+}
+
+type testDoc struct {
+	testBase
+	Title   string            `xqml:"title.#text"`
+	Lang    string            `xqml:"title.@lang"`
+	Entries []testEntry       `xqml:"entry"`
+	Extra   map[string]string `xqml:"extra"`
+	Date    time.Time         `xqml:"date"`
+	Empty   *testEntry        `xqml:"empty"`
+	Ignored string            `xqml:"-"`
+	Note    string
+}
+
+func Test_Marshal(t *testing.T) {
+	date := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	doc := testDoc{
+		testBase: testBase{Version: "2"},
+		Title:    "News",
+		Lang:     "en",
+		Entries:  []testEntry{{Id: 1, Name: "a", Tags: []string{"x", "y"}, Price: 1.5}, {Id: 2, Name: "b"}},
+		Extra:    map[string]string{"b": "2", "a": "1"},
+		Date:     date,
+		Ignored:  "no",
+		Note:     "yes",
+	}
+	testMarshal(t, map[string]any{"feed": doc}, map[string]any{"feed": map[string]any{
+		"@version": "2",
+		"title":    map[string]any{"#text": "News", "@lang": "en"},
+		"entry": []any{
+			map[string]any{"@id": 1, "name": "a", "tags": map[string]any{"tag": []any{"x", "y"}}, "price": 1.5},
+			map[string]any{"@id": 2, "name": "b", "tags": map[string]any{"tag": []any{}}},
+		},
+		"extra": map[string]any{"a": "1", "b": "2"},
+		"date":  "2023-01-02T03:04:05Z",
+		"empty": nil,
+		"Note":  "yes",
+	}})
+	// typed maps and slices
+	testMarshal(t, map[string][]int{"a": {1, 2}}, map[string]any{"a": []any{1, 2}})
+	testMarshal(t, map[string]map[string]string{"r": {"@x": "1", "e": "2"}}, map[string]any{"r": map[string]any{"@x": "1", "e": "2"}})
+	testMarshal(t, []string{"a", "b"}, []any{"a", "b"})
+	testMarshal(t, testEntry{Id: 3}, map[string]any{"@id": 3, "name": "", "tags": map[string]any{"tag": []any{}}})
+}
+
+func Test_MarshalGeneric(t *testing.T) {
+	// generic values are not copied
+	inner := map[string]any{"e": []any{1, "a", nil}}
+	value := map[string]any{"r": inner}
+	g, err := NewEncoder(nil).generic(value)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if reflect.ValueOf(g).Pointer() != reflect.ValueOf(value).Pointer() {
+		t.Errorf("ERROR: generic map copied")
+	}
+	// only maps containing other values are copied
+	value = map[string]any{"r": inner, "s": testBase{Version: "1"}}
+	g, err = NewEncoder(nil).generic(value)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	m := g.(map[string]any)
+	if reflect.ValueOf(m).Pointer() == reflect.ValueOf(value).Pointer() || reflect.ValueOf(m["r"]).Pointer() != reflect.ValueOf(inner).Pointer() {
+		t.Errorf("ERROR: received %v", m)
+	}
+}
+
+func testMarshal(t *testing.T, value any, expected any) {
+	t.Logf("")
+	res, err := encode(value)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	rxml, err := encode(expected)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	t.Logf("go => xml: %s => %s\n", Stringify(value), rxml)
+	if res != rxml {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
@@ -0,0 +1,53 @@
+package xqml
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Mixed(t *testing.T) {
+	testMixed(t, `<p>Hello <b>world</b> again</p>`, `{"p":{"#mixed":["Hello ",{"b":"world"}," again"]}}`, false)
+	testMixed(t, `<p x="1">Hello <b>big <i>wide</i></b> <i>world</i>!</p>`, `{"p":{"#mixed":["Hello ",{"b":{"#mixed":["big ",{"i":"wide"}]}}," ",{"i":"world"},"!"],"@x":"1"}}`, false)
+	testMixed(t, `<p><b>a</b><b>b</b>c</p>`, `{"p":{"#mixed":[{"b":"a"},{"b":"b"},"c"]}}`, false)
+	// not mixed
+	testMixed(t, `<p>1</p>`, `{"p":1}`, false)
+	testMixed(t, `<p> <b>1</b> </p>`, `{"p":{"b":1}}`, false)
+	// ordered
+	testMixed(t, `<p y="1" x="2">Hello <b>world</b></p>`, `{"p":{"@y":"1","@x":"2","#mixed":["Hello ",{"b":"world"}]}}`, true)
+}
+
+func testMixed(t *testing.T, src string, rjson string, ordered bool) {
+	t.Logf("")
+	t.Logf("xml => json: %s => %s\n", src, rjson)
+	x := NewDecoder(strings.NewReader(src))
+	x.Mixed = true
+	x.Ordered = ordered
+	var v any
+	err := x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	res := Stringify(v)
+	if res != rjson {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+	// json round-trip
+	if ordered {
+		v, err = ToOrderedJson([]byte(res))
+	} else {
+		v, err = ToJson([]byte(res))
+	}
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	rxml := strings.ReplaceAll(src, "> </p>", "></p>")
+	rxml = strings.ReplaceAll(rxml, "<p> ", "<p>")
+	t.Logf("json => xml: %s => %s\n", rjson, rxml)
+	res, err = encode(v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res != rxml {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
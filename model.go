@@ -0,0 +1,214 @@
+package xqml
+
+import "sort"
+
+// Kinds of content model particles.
+const (
+	particleElement = iota
+	particleAny
+	particleSequence
+	particleChoice
+	particleAll
+)
+
+// maxModelOccurs is the maximum number of occurrences of a particle checked by a content model,
+// more occurrences being unbounded. The number of occurrences of elements are checked separately.
+const maxModelOccurs = 16
+
+// maxModelStates is the maximum number of states of a content model, larger models not being checked.
+const maxModelStates = 4096
+
+// schemaParticle is a particle of a content model: an element, a wildcard, or a sequence, choice or all group,
+// with its minimum and maximum occurrences, -1 being unbounded.
+type schemaParticle struct {
+	kind      int
+	name      string
+	min       int
+	max       int
+	particles []*schemaParticle
+}
+
+// add adds a particle to a group, nil particles being ignored.
+func (p *schemaParticle) add(child *schemaParticle) {
+	if child != nil {
+		p.particles = append(p.particles, child)
+	}
+}
+
+// contentModel is a content model compiled to a non-deterministic automaton over the names of child elements,
+// to check the order of elements in sequences and the exclusivity of choices.
+// State 0 is the initial state.
+type contentModel struct {
+	edges    [][]contentEdge
+	final    int
+	overflow bool
+}
+
+// contentEdge is a transition of a content model, matching an element name, any element, or nothing.
+type contentEdge struct {
+	name string
+	any  bool
+	to   int
+}
+
+// newContentModel returns the automaton of the particles of a content model, in sequence,
+// or nil if it is too large.
+func newContentModel(particles []*schemaParticle) *contentModel {
+	m := &contentModel{}
+	m.final = m.particle(&schemaParticle{kind: particleSequence, min: 1, max: 1, particles: particles}, m.state())
+	if m.overflow {
+		return nil
+	}
+	return m
+}
+
+// state adds a state.
+func (m *contentModel) state() int {
+	if len(m.edges) >= maxModelStates {
+		m.overflow = true
+	}
+	m.edges = append(m.edges, nil)
+	return len(m.edges) - 1
+}
+
+func (m *contentModel) edge(from int, to int, name string, any bool) {
+	m.edges[from] = append(m.edges[from], contentEdge{name: name, any: any, to: to})
+}
+
+// particle adds the transitions of a particle with its occurrences from a state, and returns its end state.
+func (m *contentModel) particle(p *schemaParticle, from int) int {
+	min, max := p.min, p.max
+	if min > maxModelOccurs {
+		min = maxModelOccurs
+	}
+	if max > maxModelOccurs {
+		max = -1
+	}
+	for i := 0; i < min && !m.overflow; i++ {
+		from = m.once(p, from)
+	}
+	if max < 0 {
+		loop := m.state()
+		m.edge(from, loop, "", false)
+		m.edge(m.once(p, loop), loop, "", false)
+		return loop
+	}
+	end := m.state()
+	for i := min; i < max && !m.overflow; i++ {
+		m.edge(from, end, "", false)
+		from = m.once(p, from)
+	}
+	m.edge(from, end, "", false)
+	return end
+}
+
+// once adds the transitions of a single occurrence of a particle from a state, and returns its end state.
+// Elements of an all group can occur in any order, their number of occurrences being checked separately.
+func (m *contentModel) once(p *schemaParticle, from int) int {
+	if m.overflow {
+		return from
+	}
+	switch p.kind {
+	case particleElement, particleAny:
+		to := m.state()
+		m.edge(from, to, p.name, p.kind == particleAny)
+		return to
+	case particleChoice:
+		end := m.state()
+		for _, child := range p.particles {
+			m.edge(m.particle(child, from), end, "", false)
+		}
+		return end
+	case particleAll:
+		loop := m.state()
+		m.edge(from, loop, "", false)
+		for _, child := range p.particles {
+			m.edge(m.once(child, loop), loop, "", false)
+		}
+		return loop
+	default:
+		for _, child := range p.particles {
+			from = m.particle(child, from)
+		}
+		return from
+	}
+}
+
+// start returns the initial states.
+func (m *contentModel) start() []int {
+	return m.closure([]int{0})
+}
+
+// next returns the states reached from states by an element, none if it is not expected.
+func (m *contentModel) next(states []int, name string) []int {
+	var res []int
+	for _, state := range states {
+		for _, edge := range m.edges[state] {
+			if edge.any || (edge.name != "" && edge.name == name) {
+				res = append(res, edge.to)
+			}
+		}
+	}
+	return m.closure(res)
+}
+
+// closure returns the states with the states reached by transitions matching nothing.
+func (m *contentModel) closure(states []int) []int {
+	seen := make(map[int]bool, len(states))
+	for _, state := range states {
+		seen[state] = true
+	}
+	for i := 0; i < len(states); i++ {
+		for _, edge := range m.edges[states[i]] {
+			if edge.name == "" && !edge.any && !seen[edge.to] {
+				seen[edge.to] = true
+				states = append(states, edge.to)
+			}
+		}
+	}
+	return states
+}
+
+// reachable returns the states reached from states by any transitions, skipping elements.
+func (m *contentModel) reachable(states []int) []int {
+	seen := make(map[int]bool, len(states))
+	res := append([]int{}, states...)
+	for _, state := range res {
+		seen[state] = true
+	}
+	for i := 0; i < len(res); i++ {
+		for _, edge := range m.edges[res[i]] {
+			if !seen[edge.to] {
+				seen[edge.to] = true
+				res = append(res, edge.to)
+			}
+		}
+	}
+	return res
+}
+
+// accepts returns true if the content model can end in one of the states.
+func (m *contentModel) accepts(states []int) bool {
+	for _, state := range states {
+		if state == m.final {
+			return true
+		}
+	}
+	return false
+}
+
+// expected returns the sorted names of the elements expected in one of the states, wildcards being ignored.
+func (m *contentModel) expected(states []int) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, state := range states {
+		for _, edge := range m.edges[state] {
+			if edge.name != "" && !seen[edge.name] {
+				seen[edge.name] = true
+				names = append(names, edge.name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,243 @@
+package xqml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+const (
+	xmlnsPrefix = "xmlns"
+	xmlPrefix   = "xml"
+	xmlURL      = "http://www.w3.org/XML/1998/namespace"
+)
+
+// nsBinding is a namespace prefix declaration in scope.
+type nsBinding struct {
+	prefix string
+	uri    string
+}
+
+// pushNs adds the namespace declarations of an element to the scope, and returns the previous scope size.
+func (x *Decoder) pushNs(e *xml.StartElement) int {
+	n := len(x.ns)
+	for _, attr := range e.Attr {
+		if attr.Name.Space == xmlnsPrefix {
+			x.ns = append(x.ns, nsBinding{attr.Name.Local, attr.Value})
+		} else if attr.Name.Space == "" && attr.Name.Local == xmlnsPrefix {
+			x.ns = append(x.ns, nsBinding{"", attr.Value})
+		}
+	}
+	return n
+}
+
+// popNs restores the namespace scope to its previous size.
+func (x *Decoder) popNs(n int) {
+	x.ns = x.ns[:n]
+}
+
+// nsPrefix returns the prefix to use for a namespace URI, and false if the URI is kept as is.
+func (x *Decoder) nsPrefix(uri string) (string, bool) {
+	if prefix, ok := x.NsMap[uri]; ok {
+		return prefix, true
+	}
+	if !x.NsPrefixes {
+		return "", false
+	}
+	if uri == xmlURL {
+		return xmlPrefix, true
+	}
+	for i := len(x.ns) - 1; i >= 0; i-- {
+		if x.ns[i].uri == uri {
+			return x.ns[i].prefix, true
+		}
+	}
+	return "", false
+}
+
+// newName returns the name of an element or attribute, with its namespace URI or prefix if namespaces are kept.
+func (x *Decoder) newName(name *xml.Name) string {
+	if !x.Namespaces || name.Space == "" {
+		return name.Local
+	}
+	if name.Space == xmlnsPrefix {
+		return xmlnsPrefix + ":" + name.Local
+	}
+	if prefix, ok := x.nsPrefix(name.Space); ok {
+		if prefix == "" {
+			return name.Local
+		}
+		return prefix + ":" + name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// newAttrName returns the name of an attribute, or false if it is a namespace declaration to remove.
+// Namespace declarations are renamed according to NsMap, so that prefixes match the names.
+func (x *Decoder) newAttrName(attr *xml.Attr) (string, bool) {
+	decl := attr.Name.Space == xmlnsPrefix || (attr.Name.Space == "" && attr.Name.Local == xmlnsPrefix)
+	if !decl {
+		return x.newName(&attr.Name), true
+	}
+	if !x.NsDeclarations {
+		return "", false
+	}
+	if !x.Namespaces {
+		return attr.Name.Local, true
+	}
+	if prefix, ok := x.NsMap[attr.Value]; ok {
+		if prefix == "" {
+			return xmlnsPrefix, true
+		}
+		return xmlnsPrefix + ":" + prefix, true
+	}
+	return x.newName(&attr.Name), true
+}
+
+// startElement writes the start of an element, resolving namespace URIs and prefixes of its name and attributes.
+// It returns the end of the element, and the previous namespace scope size.
+func (x *Encoder) startElement(parent string, attrs []*tag) (xml.EndElement, int, error) {
+	n := len(x.ns)
+	// add namespace declarations to the scope
+	for _, attr := range attrs {
+		if attr.name == xmlnsPrefix {
+			x.ns = append(x.ns, nsBinding{"", fmt.Sprintf("%v", attr.value)})
+		} else if strings.HasPrefix(attr.name, xmlnsPrefix+":") {
+			x.ns = append(x.ns, nsBinding{attr.name[len(xmlnsPrefix)+1:], fmt.Sprintf("%v", attr.value)})
+		}
+	}
+	// resolve names, adding missing declarations
+	var decls []*tag
+	local, err := x.nsName(parent, false, &decls)
+	if err != nil {
+		x.ns = x.ns[:n]
+		return xml.EndElement{}, n, err
+	}
+	name := xml.Name{Local: local}
+	names := make([]*tag, len(attrs))
+	for i, attr := range attrs {
+		local, err = x.nsName(attr.name, true, &decls)
+		if err != nil {
+			x.ns = x.ns[:n]
+			return xml.EndElement{}, n, err
+		}
+		names[i] = &tag{local, attr.value}
+	}
+	if decls != nil {
+		names = append(decls, names...)
+	}
+	if len(names) == 0 {
+		names = nil
+	}
+	start := xml.StartElement{
+		Name: name,
+		Attr: *newAttrs(names),
+	}
+	err = x.encoder.EncodeToken(start)
+	path := parent
+	if len(x.paths) > 0 {
+		path = newPath(x.paths[len(x.paths)-1], parent)
+	}
+	x.paths = append(x.paths, path)
+	return xml.EndElement{Name: name}, n, err
+}
+
+// endElement writes the end of an element and restores the namespace scope.
+func (x *Encoder) endElement(end xml.EndElement, n int) error {
+	x.ns = x.ns[:n]
+	x.paths = x.paths[:len(x.paths)-1]
+	if x.isVoid(end) {
+		return x.endVoid(end)
+	}
+	return x.encoder.EncodeToken(end)
+}
+
+// nsName returns the name of an element or attribute, written as "prefix:local".
+// Namespace URIs, like "http://www.w3.org/2005/Atom:feed", are converted to prefixes,
+// using prefixes in scope, then NsMap, then generated prefixes.
+// Prefixes must be in scope or in NsMap. Missing declarations are added to decls.
+func (x *Encoder) nsName(key string, attr bool, decls *[]*tag) (string, error) {
+	i := strings.LastIndex(key, ":")
+	if i < 0 {
+		return key, nil
+	}
+	space, local := key[:i], key[i+1:]
+	if space == xmlnsPrefix || space == xmlPrefix {
+		return key, nil
+	}
+	// prefix
+	if !strings.ContainsAny(space, ":/") {
+		if _, ok := x.nsURI(space); ok {
+			return key, nil
+		}
+		for uri, prefix := range x.NsMap {
+			if prefix == space {
+				x.declare(prefix, uri, decls)
+				return key, nil
+			}
+		}
+		return "", fmt.Errorf("undeclared namespace prefix '%s' in '%s'", space, key)
+	}
+	// namespace URI
+	uri := space
+	if uri == xmlURL {
+		return xmlPrefix + ":" + local, nil
+	}
+	prefix, ok := x.nsPrefix(uri, attr)
+	if !ok {
+		prefix, ok = x.NsMap[uri]
+		if !ok || (attr && prefix == "") {
+			prefix = x.newPrefix()
+		}
+		x.declare(prefix, uri, decls)
+	}
+	if prefix == "" {
+		return local, nil
+	}
+	return prefix + ":" + local, nil
+}
+
+// nsURI returns the namespace URI bound to a prefix in scope.
+func (x *Encoder) nsURI(prefix string) (string, bool) {
+	for i := len(x.ns) - 1; i >= 0; i-- {
+		if x.ns[i].prefix == prefix {
+			return x.ns[i].uri, true
+		}
+	}
+	return "", false
+}
+
+// nsPrefix returns a prefix bound to a namespace URI in scope. Attributes can't use the default namespace.
+func (x *Encoder) nsPrefix(uri string, attr bool) (string, bool) {
+	for i := len(x.ns) - 1; i >= 0; i-- {
+		b := x.ns[i]
+		if b.uri != uri || (attr && b.prefix == "") {
+			continue
+		}
+		if u, _ := x.nsURI(b.prefix); u == uri {
+			return b.prefix, true
+		}
+	}
+	return "", false
+}
+
+// newPrefix returns a new prefix, not in scope.
+func (x *Encoder) newPrefix() string {
+	for {
+		x.nsCount++
+		prefix := fmt.Sprintf("ns%d", x.nsCount)
+		if _, ok := x.nsURI(prefix); !ok {
+			return prefix
+		}
+	}
+}
+
+// declare adds a namespace declaration to the scope and to decls.
+func (x *Encoder) declare(prefix string, uri string, decls *[]*tag) {
+	x.ns = append(x.ns, nsBinding{prefix, uri})
+	name := xmlnsPrefix
+	if prefix != "" {
+		name = xmlnsPrefix + ":" + prefix
+	}
+	*decls = append(*decls, &tag{name, uri})
+}
@@ -0,0 +1,97 @@
+package xqml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_Namespaces(t *testing.T) {
+	src := `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/elements/1.1/"><title xml:lang="en">x</title><dc:creator>y</dc:creator></feed>`
+	// uris
+	testNamespaces(t, src, false, nil, true, `{"http://www.w3.org/2005/Atom:feed":{"@xmlns":"http://www.w3.org/2005/Atom","@xmlns:dc":"http://purl.org/dc/elements/1.1/","http://purl.org/dc/elements/1.1/:creator":"y","http://www.w3.org/2005/Atom:title":{"#text":"x","@http://www.w3.org/XML/1998/namespace:lang":"en"}}}`)
+	// prefixes
+	testNamespaces(t, src, true, nil, true, `{"feed":{"@xmlns":"http://www.w3.org/2005/Atom","@xmlns:dc":"http://purl.org/dc/elements/1.1/","dc:creator":"y","title":{"#text":"x","@xml:lang":"en"}}}`)
+	testNamespaces(t, src, true, nil, false, `{"feed":{"dc:creator":"y","title":{"#text":"x","@xml:lang":"en"}}}`)
+	// mapped uris
+	nsMap := map[string]string{"http://www.w3.org/2005/Atom": "atom", "http://purl.org/dc/elements/1.1/": "d"}
+	testNamespaces(t, src, false, nsMap, true, `{"atom:feed":{"@xmlns:atom":"http://www.w3.org/2005/Atom","@xmlns:d":"http://purl.org/dc/elements/1.1/","atom:title":{"#text":"x","@http://www.w3.org/XML/1998/namespace:lang":"en"},"d:creator":"y"}}`)
+	testNamespaces(t, src, true, nsMap, true, `{"atom:feed":{"@xmlns:atom":"http://www.w3.org/2005/Atom","@xmlns:d":"http://purl.org/dc/elements/1.1/","atom:title":{"#text":"x","@xml:lang":"en"},"d:creator":"y"}}`)
+	// scopes
+	testNamespaces(t, `<a:r xmlns:a="u1"><a:e xmlns:a="u2"><a:f/></a:e><a:g/></a:r>`, true, nil, false, `{"a:r":{"a:e":{"a:f":null},"a:g":null}}`)
+	testNamespaces(t, `<r xmlns:a="u1"><e xmlns:b="u1"><a:f/></e><a:g/></r>`, true, nil, false, `{"r":{"a:g":null,"e":{"b:f":null}}}`)
+}
+
+func testNamespaces(t *testing.T, src string, prefixes bool, nsMap map[string]string, decls bool, rjson string) {
+	t.Logf("")
+	t.Logf("xml => json: %s => %s\n", src, rjson)
+	x := NewDecoder(strings.NewReader(src))
+	x.NsPrefixes = prefixes
+	x.NsMap = nsMap
+	x.NsDeclarations = decls
+	var v map[string]any
+	err := x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res := Stringify(v); res != rjson {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
+
+func Test_NamespacesEncode(t *testing.T) {
+	src := `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:creator>y</dc:creator><title xml:lang="en">x</title></feed>`
+	// declarations in scope
+	testNamespacesEncode(t, src, true, nil, src)
+	// missing declarations
+	testNamespacesEncode(t, src, false, nil, `<ns1:feed xmlns:ns1="http://www.w3.org/2005/Atom"><ns2:creator xmlns:ns2="http://purl.org/dc/elements/1.1/">y</ns2:creator><ns1:title xml:lang="en">x</ns1:title></ns1:feed>`)
+	testNamespacesEncode(t, src, false, map[string]string{"http://www.w3.org/2005/Atom": "", "http://purl.org/dc/elements/1.1/": "dc"}, `<feed xmlns="http://www.w3.org/2005/Atom"><dc:creator xmlns:dc="http://purl.org/dc/elements/1.1/">y</dc:creator><title xml:lang="en">x</title></feed>`)
+	// prefixed names
+	soap := map[string]string{"http://schemas.xmlsoap.org/soap/envelope/": "soap"}
+	testWriteNamespaces(t, map[string]any{"soap:Envelope": map[string]any{"soap:Body": map[string]any{"@soap:mustUnderstand": "1", "x": 1}}}, soap, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body soap:mustUnderstand="1"><x>1</x></soap:Body></soap:Envelope>`)
+	testWriteNamespaces(t, map[string]any{"s:Envelope": map[string]any{"@xmlns:s": "u", "s:Body": 1}}, soap, `<s:Envelope xmlns:s="u"><s:Body>1</s:Body></s:Envelope>`)
+	testWriteNamespaces(t, map[string]any{"n:r": 1}, soap, `undeclared namespace prefix 'n' in 'n:r'`)
+	testWriteNamespaces(t, map[string]any{"u:v:r": map[string]any{"@u:v:a": 1}}, nil, `<ns1:r xmlns:ns1="u:v" ns1:a="1"></ns1:r>`)
+}
+
+func Test_NamespacesPrefixesEncode(t *testing.T) {
+	src := `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>1</s:Body></s:Envelope>`
+	x := NewDecoder(strings.NewReader(src))
+	x.NsPrefixes = true
+	x.NsDeclarations = false
+	var v map[string]any
+	err := x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	// prefixes without declarations can't be written, unless they are in NsMap
+	testWriteNamespaces(t, v, nil, `undeclared namespace prefix 's' in 's:Envelope'`)
+	testWriteNamespaces(t, v, map[string]string{"http://schemas.xmlsoap.org/soap/envelope/": "s"}, src)
+}
+
+func testNamespacesEncode(t *testing.T, src string, decls bool, nsMap map[string]string, rxml string) {
+	x := NewDecoder(strings.NewReader(src))
+	x.NsDeclarations = decls
+	var v map[string]any
+	err := x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	testWriteNamespaces(t, v, nsMap, rxml)
+}
+
+func testWriteNamespaces(t *testing.T, v map[string]any, nsMap map[string]string, rxml string) {
+	t.Logf("")
+	t.Logf("json => xml: %s => %s\n", Stringify(v), rxml)
+	writer := new(bytes.Buffer)
+	e := NewEncoder(writer)
+	e.NsMap = nsMap
+	err := e.Encode(v)
+	res := writer.String()
+	if err != nil {
+		res = err.Error()
+	}
+	if res != rxml {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
@@ -0,0 +1,226 @@
+package xqml
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Node wraps a decoded value, giving access to its content whatever its shape.
+//
+// A decoded element can be nil when empty, a scalar, a map with attributes and text, or a list
+// when repeated, so that Node methods handle all these shapes the same way:
+// a list is a node with multiple values, and a scalar is an element with text only.
+//
+//	n := NewNode(v)
+//	for _, e := range n.Get("r.e").List() {
+//		id, err := e.Attr("id").Int()
+//		...
+//	}
+type Node struct {
+	value  any
+	path   string
+	exists bool
+	conv   Convention
+}
+
+// NewNode returns a node wrapping a decoded value, using the default Xqml convention.
+func NewNode(value any) Node {
+	return Node{value: value, exists: true, conv: Xqml}
+}
+
+// WithConvention returns the node using a convention, which must match the decoder one.
+func (n Node) WithConvention(c Convention) Node {
+	n.conv = c
+	return n
+}
+
+// Value returns the wrapped value, being a []any for multiple values.
+func (n Node) Value() any {
+	return n.value
+}
+
+// Path returns the dotted path of the node, relative to the node created by NewNode.
+func (n Node) Path() string {
+	return n.path
+}
+
+// Exists returns true if the node was found, even if it is an empty element.
+func (n Node) Exists() bool {
+	return n.exists
+}
+
+// Len returns the number of values of the node, 0 if it does not exist.
+func (n Node) Len() int {
+	return len(n.values())
+}
+
+// List returns the values of the node as nodes, being empty if it does not exist.
+func (n Node) List() []Node {
+	values := n.values()
+	res := make([]Node, len(values))
+	for i, v := range values {
+		res[i] = n.child(v, n.path)
+	}
+	return res
+}
+
+// Index returns the i-th value of the node, 0-based, or a missing node.
+func (n Node) Index(i int) Node {
+	values := n.values()
+	if i < 0 || i >= len(values) {
+		return n.missing(n.path)
+	}
+	return n.child(values[i], n.path)
+}
+
+// Each calls fn for each value of the node, stopping on the first error.
+func (n Node) Each(fn func(n Node) error) error {
+	for _, item := range n.List() {
+		err := fn(item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the node at a dotted path, like "r.e", "r.e.@id" or "r.e.#text".
+// The path is looked up in all values of the node, so that the result has multiple values
+// when a list is found along the path.
+func (n Node) Get(path string) Node {
+	var steps []*step
+	for _, name := range strings.Split(path, ".") {
+		switch {
+		case name == "#text":
+			steps = append(steps, &step{kind: stepText})
+		case strings.HasPrefix(name, "@"):
+			steps = append(steps, &step{kind: stepAttr, name: name[1:]})
+		default:
+			steps = append(steps, &step{kind: stepChild, name: name})
+		}
+	}
+	return n.eval(steps, newPath(n.path, path))
+}
+
+// Select returns the node with the values matching a query expression, see Query.
+func (n Node) Select(expr string) (Node, error) {
+	q, err := CompileQuery(expr)
+	if err != nil {
+		return Node{}, err
+	}
+	return n.eval(q.steps, newPath(n.path, expr)), nil
+}
+
+// Attr returns the node of an attribute.
+func (n Node) Attr(name string) Node {
+	return n.eval([]*step{{kind: stepAttr, name: name}}, newPath(n.path, "@"+name))
+}
+
+// Text returns the text of the first value, or "" if there is none.
+func (n Node) Text() string {
+	text, _ := n.text()
+	return text
+}
+
+// Int returns the text of the first value as an int64, or an error if it is missing or invalid.
+// Integral floats, like 1.5e+20 cast to a float, are converted, and values beyond the int64 range,
+// like large uint64 values, return an error wrapping strconv.ErrRange.
+func (n Node) Int() (int64, error) {
+	text, err := n.text()
+	if err != nil {
+		return 0, err
+	}
+	s := strings.TrimSpace(text)
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err == nil {
+		return i, nil
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("int value '%s' out of range at '%s': %w", text, n.path, strconv.ErrRange)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid int value '%s' at '%s'", text, n.path)
+	}
+	// float64(math.MaxInt64) rounds up to 2^63, which is out of range
+	if f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, fmt.Errorf("int value '%s' out of range at '%s': %w", text, n.path, strconv.ErrRange)
+	}
+	return int64(f), nil
+}
+
+// Float returns the text of the first value as a float64, or an error if it is missing or invalid.
+func (n Node) Float() (float64, error) {
+	text, err := n.text()
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid float value '%s' at '%s'", text, n.path)
+	}
+	return f, nil
+}
+
+// Bool returns the text of the first value as a bool, or an error if it is missing or invalid.
+func (n Node) Bool() (bool, error) {
+	text, err := n.text()
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(text))
+	if err != nil {
+		return false, fmt.Errorf("invalid bool value '%s' at '%s'", text, n.path)
+	}
+	return b, nil
+}
+
+// text returns the text of the first value, or an error if there is no text.
+func (n Node) text() (string, error) {
+	values := n.values()
+	if len(values) == 0 {
+		return "", fmt.Errorf("missing value at '%s'", n.path)
+	}
+	q := &Query{conv: n.conv}
+	texts := q.texts(values[0])
+	if len(texts) == 0 || texts[0] == nil {
+		return "", fmt.Errorf("missing text at '%s'", n.path)
+	}
+	return q.text(values[0]), nil
+}
+
+// values returns the values of the node.
+func (n Node) values() []any {
+	if !n.exists {
+		return nil
+	}
+	return nodeValues(n.value)
+}
+
+// eval returns the node with the values selected by steps in all values of the node.
+func (n Node) eval(steps []*step, path string) Node {
+	q := &Query{conv: n.conv}
+	var res []any
+	for _, v := range n.values() {
+		res = append(res, q.eval(steps, v)...)
+	}
+	switch len(res) {
+	case 0:
+		return n.missing(path)
+	case 1:
+		return n.child(res[0], path)
+	default:
+		return n.child(res, path)
+	}
+}
+
+func (n Node) child(value any, path string) Node {
+	return Node{value: value, path: path, exists: true, conv: n.conv}
+}
+
+func (n Node) missing(path string) Node {
+	return Node{path: path, conv: n.conv}
+}
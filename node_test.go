@@ -0,0 +1,91 @@
+package xqml
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func Test_Node(t *testing.T) {
+	// the same code works with one or many items, with or without attributes
+	for src, expected := range map[string]string{
+		`<r><e id="1">a</e></r>`:                               `[1:a:]`,
+		`<r><e id="1">a</e><e id="2">b</e></r>`:                `[1:a: 2:b:]`,
+		`<r><e id="1"><n>a</n></e><e><n>b</n><n>c</n></e></r>`: `[1::a 0::b]`,
+		`<r><e>a</e><e/><e id="x">b</e></r>`:                   `[0:a: 0:: 0:b:]`,
+	} {
+		v, err := decode(src, true, true, nil, false)
+		if err != nil {
+			t.Errorf("ERROR: %v", err)
+		}
+		var res []string
+		_ = NewNode(v).Get("r.e").Each(func(e Node) error {
+			id, _ := e.Attr("id").Int()
+			res = append(res, fmt.Sprintf("%d:%s:%s", id, e.Text(), e.Get("n").Text()))
+			return nil
+		})
+		t.Logf("%s => %v", src, res)
+		testNode(t, fmt.Sprint(res), expected)
+	}
+	v, _ := decode(`<r><e id="1" ok="true"><n>1.5</n></e><e id="2"><n>b</n><n>c</n></e><f/></r>`, true, true, nil, false)
+	n := NewNode(v)
+	testNode(t, n.Get("r.e").Len(), 2)
+	testNode(t, n.Get("r.e.n").Len(), 3)
+	testNode(t, n.Get("r.e.n").Text(), "1.5")
+	testNode(t, n.Get("r.e").Index(1).Get("n").Index(1).Text(), "c")
+	testNode(t, n.Get("r.e.@id").Text(), "1")
+	testNode(t, n.Get("r.f").Exists(), true)
+	testNode(t, n.Get("r.f").Len(), 1)
+	testNode(t, n.Get("r.f").Text(), "")
+	testNode(t, n.Get("r.g").Exists(), false)
+	testNode(t, n.Get("r.g").Len(), 0)
+	testNode(t, n.Get("r.e").Index(2).Exists(), false)
+	testNode(t, n.Get("r.e.n").Path(), "r.e.n")
+	f, err := n.Get("r.e.n").Float()
+	testNode(t, f, 1.5)
+	testNode(t, err, nil)
+	b, err := n.Get("r.e").Attr("ok").Bool()
+	testNode(t, b, true)
+	testNode(t, err, nil)
+	s, err := n.Select("//e[@id=2]/n[2]")
+	testNode(t, s.Text(), "c")
+	testNode(t, err, nil)
+	// errors
+	_, err = n.Get("r.g").Int()
+	testNode(t, fmt.Sprint(err), "missing value at 'r.g'")
+	_, err = n.Get("r.f").Int()
+	testNode(t, fmt.Sprint(err), "missing text at 'r.f'")
+	_, err = n.Get("r.e").Index(1).Get("n").Int()
+	testNode(t, fmt.Sprint(err), "invalid int value 'b' at 'r.e.n'")
+	// integral floats and out of range values
+	x := NewDecoder(strings.NewReader(`<r><f>1e18</f><s>1.2e1</s><o>1.5e+20</o><u>18446744073709551615</u></r>`))
+	_ = x.Decode(&v)
+	n = NewNode(v)
+	i, err := n.Get("r.f").Int()
+	testNode(t, i, int64(1000000000000000000))
+	testNode(t, err, nil)
+	i, err = n.Get("r.s").Int()
+	testNode(t, i, int64(12))
+	testNode(t, err, nil)
+	_, err = n.Get("r.o").Int()
+	testNode(t, fmt.Sprint(err), "int value '1.5e+20' out of range at 'r.o': value out of range")
+	testNode(t, errors.Is(err, strconv.ErrRange), true)
+	_, err = n.Get("r.u").Int()
+	testNode(t, fmt.Sprint(err), "int value '18446744073709551615' out of range at 'r.u': value out of range")
+	testNode(t, errors.Is(err, strconv.ErrRange), true)
+	// conventions
+	x = NewDecoder(strings.NewReader(`<r><e id="1">a</e></r>`))
+	x.SetConvention(BadgerFish)
+	_ = x.Decode(&v)
+	n = NewNode(v).WithConvention(BadgerFish)
+	testNode(t, n.Get("r.e").Text(), "a")
+	testNode(t, n.Get("r.e.@id").Text(), "1")
+}
+
+func testNode(t *testing.T, value any, expected any) {
+	if value != expected {
+		t.Errorf("ERROR: received %v, expected %v", value, expected)
+	}
+}
@@ -0,0 +1,201 @@
+package xqml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OrderedMap is a map keeping its keys in insertion order.
+//
+// It is returned by Decoder when Ordered is true, and written by Encoder in order,
+// so that elements order is kept on round-trip, interleaved elements being stored in "#mixed", see Decoder.Ordered.
+type OrderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+// NewOrderedMap returns a new empty ordered map.
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{values: make(map[string]any)}
+}
+
+// Get returns the value of key, and whether it is present.
+func (m *OrderedMap) Get(key string) (any, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Set sets the value of key. A new key is added at the end, an existing key keeps its position.
+func (m *OrderedMap) Set(key string, value any) {
+	if m.values == nil {
+		m.values = make(map[string]any)
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Delete removes key.
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns the keys in order.
+func (m *OrderedMap) Keys() []string {
+	return m.keys
+}
+
+// Len returns the number of keys.
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+func (m *OrderedMap) value() any {
+	return m
+}
+
+// MarshalJSON writes the map as a JSON object, keeping keys order.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		b, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+		buf.WriteByte(':')
+		b, err = json.Marshal(m.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON reads a JSON object, keeping keys order. Nested objects are read as *OrderedMap,
+// and numbers as json.Number to keep their exact text.
+func (m *OrderedMap) UnmarshalJSON(b []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	v, err := readOrdered(decoder)
+	if err != nil {
+		return err
+	}
+	om, ok := v.(*OrderedMap)
+	if !ok {
+		return fmt.Errorf("invalid JSON, must be an object")
+	}
+	*m = *om
+	return nil
+}
+
+func readOrdered(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		m := NewOrderedMap()
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			m.Set(key.(string), value)
+		}
+		_, err = decoder.Token()
+		return m, err
+	case json.Delim('['):
+		s := []any{}
+		for decoder.More() {
+			value, err := readOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, value)
+		}
+		_, err = decoder.Token()
+		return s, err
+	}
+	return token, nil
+}
+
+// ToOrderedJson reads a JSON object, keeping keys order.
+func ToOrderedJson(b []byte) (*OrderedMap, error) {
+	v := NewOrderedMap()
+	err := json.Unmarshal(b, v)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// plainMap is a map[string]any used as a decoded element content.
+type plainMap map[string]any
+
+func (m plainMap) Get(key string) (any, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+func (m plainMap) Set(key string, value any) {
+	m[key] = value
+}
+
+func (m plainMap) Delete(key string) {
+	delete(m, key)
+}
+
+func (m plainMap) Len() int {
+	return len(m)
+}
+
+func (m plainMap) value() any {
+	return map[string]any(m)
+}
+
+// mapEntries returns the entries of a map[string]any sorted by key, or of an *OrderedMap in order.
+func mapEntries(value any) ([]*tag, bool) {
+	switch m := value.(type) {
+	case map[string]any:
+		entries := make([]*tag, 0, len(m))
+		for k, v := range m {
+			entries = append(entries, &tag{k, v})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return strings.Compare(entries[i].name, entries[j].name) < 0
+		})
+		return entries, true
+	case *OrderedMap:
+		entries := make([]*tag, 0, m.Len())
+		for _, k := range m.keys {
+			entries = append(entries, &tag{k, m.values[k]})
+		}
+		return entries, true
+	}
+	return nil, false
+}
@@ -0,0 +1,60 @@
+package xqml
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Ordered(t *testing.T) {
+	testOrdered(t, `<r><b>1</b><a y="2" x="1">2</a><c></c></r>`, `{"r":{"b":1,"a":{"@y":"2","@x":"1","#text":2},"c":null}}`)
+	testOrdered(t, `<r><z><b>1</b></z><a>1</a><a>2</a></r>`, `{"r":{"z":{"b":1},"a":[1,2]}}`)
+	// interleaved elements are kept in order
+	testOrdered(t, `<r><a>1</a><b>2</b><a>3</a></r>`, `{"r":{"#mixed":[{"a":1},{"b":2},{"a":3}]}}`)
+	testOrdered(t, `<r x="1"><a><c>1</c><d></d><c>2</c></a><a>3</a><b></b><a></a></r>`, `{"r":{"@x":"1","#mixed":[{"a":{"#mixed":[{"c":1},{"d":null},{"c":2}]}},{"a":3},{"b":null},{"a":null}]}}`)
+	testOrdered(t, `<r><a>1</a><!--c--><a>2</a><?pi x?></r>`, `{"r":{"#mixed":[{"a":1},{"#comment":"c"},{"a":2},{"?pi":"x"}]}}`)
+}
+
+func Test_OrderedJson(t *testing.T) {
+	src := `{"r":{"b":[1,{"@y":"2","@x":"1","d":true}],"a":null}}`
+	v, err := ToOrderedJson([]byte(src))
+	if err != nil {
+		t.Fatalf("ERROR: %v", err)
+	}
+	if res := Stringify(v); res != src {
+		t.Errorf("ERROR: received %s", res)
+	}
+	res, err := encode(v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res != `<r><b>1</b><b y="2" x="1"><d>true</d></b><a></a></r>` {
+		t.Errorf("ERROR: received %s", res)
+	}
+}
+
+// testOrdered checks the decoded value, and that it is encoded back to the same document.
+func testOrdered(t *testing.T, src string, rjson string) {
+	t.Logf("")
+	t.Logf("xml => json: %s => %s\n", src, rjson)
+	x := NewDecoder(strings.NewReader(src))
+	x.Ordered = true
+	x.Comments = true
+	x.ProcInsts = true
+	var v OrderedMap
+	err := x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	res := Stringify(&v)
+	if res != rjson {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+	t.Logf("json => xml: %s => %s\n", rjson, src)
+	res, err = encode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res != src {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
@@ -14,15 +14,40 @@ const (
 	ContentObject
 )
 
+// node is the content of a decoded element, a map[string]any or an *OrderedMap.
+type node interface {
+	Get(key string) (any, bool)
+	Set(key string, value any)
+	Delete(key string)
+	Len() int
+	value() any
+}
+
 type elem struct {
-	data    map[string]any
-	name    string
-	path    string
-	content int
+	data     node
+	name     string
+	path     string
+	depth    int
+	count    int
+	content  int
+	segments []any
+	xsd      *schemaElement
+	// text is the size of the element text, to enforce MaxTextBytes
+	text int
+	// space is the whitespace mode of the element text, see Decoder.Whitespace
+	space string
+	// blank is the pending whitespace only text of preserved elements
+	blank string
 }
 
 func (x *Decoder) parse(curr *elem, parent *elem) error {
 	for {
+		// keep token position for errors
+		x.offset = x.decoder.InputOffset()
+		x.line, x.column = x.decoder.InputPos()
+		if x.validation != nil {
+			x.validation.line, x.validation.column = x.line, x.column
+		}
 		token, err := x.decoder.Token()
 		// on error, check EOF
 		if err != nil {
@@ -30,30 +55,76 @@ func (x *Decoder) parse(curr *elem, parent *elem) error {
 				x.done = true
 				return nil
 			}
-			return err
+			return x.newError(curr.path, nil, err)
 		}
 		//
 		switch token.(type) {
 		case xml.StartElement:
 			e := token.(xml.StartElement)
 			if x.done {
-				return fmt.Errorf("invalid XML element '%s' found for non-partial parse", e.Name.Local)
+				return x.newError(curr.path, token, fmt.Errorf("invalid XML element '%s' found for non-partial parse", e.Name.Local))
 			}
 			// create new element
-			var data map[string]any
-			name := newName(x.Namespaces, &e.Name)
+			var data any
+			ns := x.pushNs(&e)
+			name := x.newName(&e.Name)
 			path := newPath(curr.path, name)
-			item := &elem{data, name, path, ContentNone}
+			item := &elem{name: name, path: path, depth: curr.depth + 1, content: ContentNone}
+			item.space = x.whitespace(curr, &e, path, name)
+			curr.blank = ""
+			// check limits before going deeper
+			x.elements++
+			switch {
+			case x.MaxDepth > 0 && item.depth > x.MaxDepth:
+				return x.limitError(path, token, ErrMaxDepth, int64(x.MaxDepth))
+			case x.MaxElements > 0 && x.elements > x.MaxElements:
+				return x.limitError(path, token, ErrMaxElements, int64(x.MaxElements))
+			case x.MaxAttributes > 0 && len(e.Attr) > x.MaxAttributes:
+				return x.limitError(path, token, ErrMaxAttributes, int64(x.MaxAttributes))
+			case x.input.entitiesExceeded(x.decoder.InputOffset()):
+				return x.limitError(path, token, ErrMaxEntityExpansions, int64(x.MaxEntityExpansions))
+			}
+			xmlAttrs := e.Attr
+			if x.validation != nil {
+				err = x.validation.start(&e.Name, path, xmlAttrs)
+				if err != nil {
+					return err
+				}
+			}
+			// use schema declaration, forcing lists and adding default attributes
+			if x.Schema != nil {
+				if curr.path == "" {
+					item.xsd = x.Schema.root(e.Name.Local)
+				} else if xsd, list := x.Schema.child(curr.xsd, e.Name.Local); xsd != nil {
+					item.xsd = xsd
+					if list {
+						x.schemaLists[path] = true
+					}
+				}
+				xmlAttrs = item.xsd.addDefaults(xmlAttrs)
+			}
+			if x.analyzer != nil {
+				x.analyzer.startElement(x, name, path, xmlAttrs)
+			}
 			// read attributes
-			if x.Attributes {
-				if len(e.Attr) > 0 {
-					data = make(map[string]any)
-					item.data = data
-					item.content = ContentObject
+			if x.conv.Attributes && len(xmlAttrs) > 0 {
+				item.data = x.newNode()
+				item.content = ContentObject
+				attrs := item.data
+				if x.conv.AttrKey != "" {
+					attrs = x.newNode()
+					item.data.Set(x.conv.AttrKey, attrs.value())
 				}
-				for _, attr := range e.Attr {
-					data["@"+newName(x.Namespaces, &attr.Name)] = attr.Value
+				for _, attr := range xmlAttrs {
+					if key, ok := x.newAttrName(&attr); ok {
+						value, err := x.castAttr(path+".@"+key, "@"+key, item.xsd.attrCast(attr.Name.Local), attr.Value)
+						if err != nil {
+							return x.newError(path, token, err)
+						}
+						attrs.Set(x.conv.AttrPrefix+key, value)
+					}
 				}
+				data = item.data.value()
 			}
 			// upgrade parent if it is empty or a value
 			x.upgradeValue(curr, parent)
@@ -64,6 +135,19 @@ func (x *Decoder) parse(curr *elem, parent *elem) error {
 			if err != nil {
 				return err
 			}
+			x.popNs(ns)
+			curr.count++
+			// keep element position for mixed content and ordered elements
+			if x.Mixed || x.ordered() {
+				curr.segments = append(curr.segments, &tag{name, x.getValue(curr, name)})
+			}
+			// stream element if it is an item
+			if x.stream != nil && x.isItem(item) {
+				err = x.streamValue(curr, item)
+				if err != nil {
+					return err
+				}
+			}
 			if curr.path == "" {
 				if x.Partials {
 					return nil
@@ -72,27 +156,124 @@ func (x *Decoder) parse(curr *elem, parent *elem) error {
 				}
 			}
 		case xml.EndElement:
+			// keep whitespace only text of preserved elements without children
+			if curr.blank != "" && curr.count == 0 {
+				x.setText(curr, parent, curr.blank)
+				if x.analyzer != nil {
+					x.analyzer.addText(curr.blank, "")
+				}
+			}
+			if x.Mixed {
+				x.setMixed(curr)
+			}
+			if x.ordered() {
+				x.setOrdered(curr)
+			}
+			if x.analyzer != nil {
+				x.analyzer.endElement()
+			}
+			if x.validation != nil {
+				return x.validation.end()
+			}
 			return nil
 		case xml.CharData:
 			cdata := string(token.(xml.CharData))
-			cdata = strings.Trim(cdata, " \n\r\t")
+			if x.input.entitiesExceeded(x.decoder.InputOffset()) {
+				return x.limitError(curr.path, token, ErrMaxEntityExpansions, int64(x.MaxEntityExpansions))
+			}
+			if curr.path != "" {
+				curr.text += len(cdata)
+				if x.MaxTextBytes > 0 && curr.text > x.MaxTextBytes {
+					return x.limitError(curr.path, token, ErrMaxTextBytes, int64(x.MaxTextBytes))
+				}
+			}
+			if x.validation != nil && curr.path != "" {
+				x.validation.text(cdata)
+			}
+			// keep raw text position for mixed content
+			if x.Mixed && curr.path != "" {
+				curr.addSegment(cdata)
+			}
+			// keep CDATA sections as is
+			if x.CData && curr.path != "" && x.input.isCData(x.offset) {
+				x.addCData(curr, parent, cdata)
+				if x.analyzer != nil {
+					x.analyzer.addCData()
+				}
+				continue
+			}
+			if curr.path == "" {
+				cdata = strings.Trim(cdata, " \n\r\t")
+			} else {
+				cdata = curr.normalize(cdata)
+			}
 			if cdata != "" {
 				if x.done {
-					return fmt.Errorf("invalid XML chardata '%s' found for non-partial parse", cdata)
+					return x.newError(curr.path, token, fmt.Errorf("invalid XML chardata '%s' found for non-partial parse", cdata))
+				}
+				if x.analyzer != nil && curr.path != "" {
+					x.analyzer.addText(cdata, x.textSep(curr))
 				}
-				value := any(cdata)
-				if x.Cast {
-					value = castValue(cdata)
+				value, err := x.castText(curr.path, curr.name, curr.xsd.textCast(), cdata)
+				if err != nil {
+					return x.newError(curr.path, token, err)
 				}
 				x.setText(curr, parent, value)
 			}
+		case xml.Comment:
+			if x.Comments {
+				x.addMisc(curr, parent, CommentKey, string(token.(xml.Comment)))
+			}
+		case xml.ProcInst:
+			if x.ProcInsts {
+				pi := token.(xml.ProcInst)
+				x.addMisc(curr, parent, ProcInstPrefix+pi.Target, string(pi.Inst))
+			}
+		case xml.Directive:
+			if x.Directives {
+				name, value, _ := strings.Cut(strings.TrimSpace(string(token.(xml.Directive))), " ")
+				x.addMisc(curr, parent, DirectivePrefix+strings.ToLower(name), strings.TrimSpace(value))
+			}
 		}
 	}
 }
 
+// addCData adds the text of a CDATA section to an element, concatenating it with previous sections.
+func (x *Decoder) addCData(curr *elem, parent *elem, cdata string) {
+	x.upgradeValue(curr, parent)
+	if prev, ok := curr.data.Get(CDataKey); ok {
+		cdata = prev.(string) + cdata
+	}
+	curr.data.Set(CDataKey, cdata)
+}
+
+// addMisc adds a comment, processing instruction or directive to an element, or to the document outside of the root element.
+func (x *Decoder) addMisc(curr *elem, parent *elem, key string, value string) {
+	if curr.path != "" {
+		x.upgradeValue(curr, parent)
+	}
+	x.addValue(curr, key, newPath(curr.path, key), value)
+	if x.ordered() && curr.path != "" {
+		curr.segments = append(curr.segments, &tag{key, value})
+	}
+}
+
+// ordered returns true if elements are decoded as *OrderedMap, see Decoder.Ordered.
+func (x *Decoder) ordered() bool {
+	return x.Ordered && !x.raw
+}
+
+// newNode returns a new element content, ordered if required.
+func (x *Decoder) newNode() node {
+	if x.Ordered && !x.raw {
+		return NewOrderedMap()
+	}
+	return plainMap{}
+}
+
 func (x *Decoder) getValue(item *elem, name string) any {
 	// if value is already set...
-	if data, isMap := item.data[name]; isMap {
+	if data, isMap := item.data.Get(name); isMap {
 		// if value is a slice, return last item
 		if slice, isSlice := data.([]any); isSlice {
 			return slice[len(slice)-1]
@@ -105,7 +286,7 @@ func (x *Decoder) getValue(item *elem, name string) any {
 
 func (x *Decoder) setValue(item *elem, name string, path string, value any) {
 	// if value is already set...
-	if data, isMap := item.data[name]; isMap {
+	if data, isMap := item.data.Get(name); isMap {
 		// if value is a slice, set last item
 		if slice, isSlice := data.([]any); isSlice {
 			slice[len(slice)-1] = value
@@ -113,70 +294,169 @@ func (x *Decoder) setValue(item *elem, name string, path string, value any) {
 		}
 	}
 	// set value or slice if forced
-	if x.forceList[name] == true || x.forceList[path] == true {
-		item.data[name] = []any{value}
+	if x.isList(name, path) {
+		item.data.Set(name, []any{value})
 	} else {
-		item.data[name] = value
+		item.data.Set(name, value)
 	}
 }
 
+// isList returns true if an element is forced to a list, by ForceList or by the Schema.
+func (x *Decoder) isList(name string, path string) bool {
+	return x.forceList[name] || x.forceList[path] || x.schemaLists[path]
+}
+
 func (x *Decoder) addValue(item *elem, name string, path string, value any) {
 	// if value is already set => transform to slice or append to slice
-	if data, isMap := item.data[name]; isMap {
+	if data, isMap := item.data.Get(name); isMap {
 		// if value is a slice
 		if slice, isSlice := data.([]any); isSlice {
 			slice = append(slice, value)
-			item.data[name] = slice
+			item.data.Set(name, slice)
 			return
 		}
 		// transform to a slice
-		item.data[name] = []any{data, value}
+		item.data.Set(name, []any{data, value})
 		return
 	}
 	// set value or slice if forced
-	if x.forceList[name] == true || x.forceList[path] == true {
-		item.data[name] = []any{value}
+	if x.isList(name, path) {
+		item.data.Set(name, []any{value})
 	} else {
-		item.data[name] = value
+		item.data.Set(name, value)
+	}
+}
+
+func (x *Decoder) removeValue(item *elem, name string) {
+	if data, isMap := item.data.Get(name); isMap {
+		// if value is a slice, remove last item
+		if slice, isSlice := data.([]any); isSlice && len(slice) > 1 {
+			item.data.Set(name, slice[:len(slice)-1])
+			return
+		}
+		item.data.Delete(name)
 	}
 }
 
 func (x *Decoder) setText(curr *elem, parent *elem, value any) {
 	switch curr.content {
 	case ContentNone:
+		if x.conv.TextObject {
+			curr.data = x.newNode()
+			curr.data.Set(x.conv.TextKey, value)
+			curr.content = ContentObject
+			x.setValue(parent, curr.name, curr.path, curr.data.value())
+			return
+		}
 		x.setValue(parent, curr.name, curr.path, value)
 		curr.content = ContentValue
 	case ContentValue:
 		text := x.getValue(parent, curr.name)
-		value = fmt.Sprintf("%v%s%v", text, x.Sep, value)
+		value = fmt.Sprintf("%v%s%v", text, x.textSep(curr), value)
 		x.setValue(parent, curr.name, curr.path, value)
 	case ContentObject:
-		if text, ok := curr.data["#text"]; ok {
-			value = fmt.Sprintf("%v%s%v", text, x.Sep, value)
+		if text, ok := curr.data.Get(x.conv.TextKey); ok {
+			value = fmt.Sprintf("%v%s%v", text, x.textSep(curr), value)
+		}
+		curr.data.Set(x.conv.TextKey, value)
+	}
+}
+
+// textSep returns the separator of multiple texts of an element, preserved texts being concatenated as is.
+func (x *Decoder) textSep(curr *elem) string {
+	if curr.space == WhitespacePreserve {
+		return ""
+	}
+	return x.Sep
+}
+
+// addSegment adds a text segment, merging it with the previous one if it is also a text segment.
+func (curr *elem) addSegment(text string) {
+	if n := len(curr.segments); n > 0 {
+		if prev, isText := curr.segments[n-1].(string); isText {
+			curr.segments[n-1] = prev + text
+			return
 		}
-		curr.data["#text"] = value
 	}
+	curr.segments = append(curr.segments, text)
+}
+
+// setMixed replaces the text and elements of an element with mixed content by a "#mixed" ordered list of segments.
+// An element has mixed content when it contains both elements and non-blank text.
+func (x *Decoder) setMixed(curr *elem) {
+	if curr.count == 0 {
+		return
+	}
+	blank := true
+	for _, s := range curr.segments {
+		if text, isText := s.(string); isText && strings.Trim(text, " \n\r\t") != "" {
+			blank = false
+			break
+		}
+	}
+	if blank {
+		return
+	}
+	// create segments
+	mixed := make([]any, len(curr.segments))
+	for i, s := range curr.segments {
+		switch s := s.(type) {
+		case string:
+			mixed[i] = s
+		case *tag:
+			e := x.newNode()
+			e.Set(s.name, s.value)
+			mixed[i] = e.value()
+			curr.data.Delete(s.name)
+		}
+	}
+	curr.data.Delete(x.conv.TextKey)
+	curr.data.Delete(CDataKey)
+	curr.data.Set(MixedKey, mixed)
+	curr.segments = nil
+}
+
+// setOrdered replaces the elements of an element by a "#mixed" ordered list of single key segments,
+// when elements with the same name are separated by other elements, comments or processing instructions.
+func (x *Decoder) setOrdered(curr *elem) {
+	interleaved := false
+	seen := make(map[string]bool)
+	last := ""
+	for _, s := range curr.segments {
+		if s, isTag := s.(*tag); isTag {
+			interleaved = interleaved || (s.name != last && seen[s.name])
+			seen[s.name] = true
+			last = s.name
+		}
+	}
+	if interleaved {
+		var mixed []any
+		for _, s := range curr.segments {
+			if s, isTag := s.(*tag); isTag {
+				e := x.newNode()
+				e.Set(s.name, s.value)
+				mixed = append(mixed, e.value())
+				curr.data.Delete(s.name)
+			}
+		}
+		curr.data.Set(MixedKey, mixed)
+	}
+	curr.segments = nil
 }
 
 func (x *Decoder) upgradeValue(curr *elem, parent *elem) {
 	switch curr.content {
 	case ContentNone:
-		curr.data = make(map[string]any)
+		curr.data = x.newNode()
 		curr.content = ContentObject
-		x.setValue(parent, curr.name, curr.path, curr.data)
+		x.setValue(parent, curr.name, curr.path, curr.data.value())
 	case ContentValue:
 		text := x.getValue(parent, curr.name)
-		curr.data = map[string]any{"#text": text}
+		curr.data = x.newNode()
+		curr.data.Set(x.conv.TextKey, text)
 		curr.content = ContentObject
-		x.setValue(parent, curr.name, curr.path, curr.data)
-	}
-}
-
-func newName(keepNs bool, name *xml.Name) string {
-	if keepNs && name.Space != "" {
-		return name.Space + ":" + name.Local
+		x.setValue(parent, curr.name, curr.path, curr.data.value())
 	}
-	return name.Local
 }
 
 func newPath(path string, name string) string {
@@ -0,0 +1,118 @@
+package xqml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_Prolog(t *testing.T) {
+	testProlog(t, `<?xml version="1.0"?><!DOCTYPE r><!-- c --><r><!--a--><e>1</e><?pi x?><!--b--></r>`,
+		`{"?xml":"version=\"1.0\"","!doctype":"r","#comment":" c ","r":{"#mixed":[{"#comment":"a"},{"e":1},{"?pi":"x"},{"#comment":"b"}]}}`,
+		`<?xml version="1.0"?><!DOCTYPE r><!-- c --><r><!--a--><e>1</e><?pi x?><!--b--></r>`)
+	// text elements become objects
+	testProlog(t, `<r>1<!--a--></r>`, `{"r":{"#text":1,"#comment":"a"}}`, `<r>1<!--a--></r>`)
+	// trailing comment, and ignored declaration and directive
+	x := NewDecoder(strings.NewReader(`<?xml version="1.0"?><!DOCTYPE r><r/><!--end-->`))
+	x.Comments = true
+	x.Ordered = true
+	var v any
+	if err := x.Decode(&v); err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res := Stringify(v); res != `{"r":null,"#comment":"end"}` {
+		t.Errorf("ERROR: received %s", res)
+	}
+	// comment after the last document
+	x = NewDecoder(strings.NewReader(`<r/><!--end-->`))
+	x.Comments = true
+	x.Partials = true
+	_ = x.Decode(&v)
+	if err := x.Decode(&v); err == nil {
+		t.Errorf("ERROR: expected EOF")
+	}
+	// misc after the root element are kept in place with ordered maps only
+	testProlog(t, `<r/><?pi x?>`, `{"r":null,"?pi":"x"}`, `<?pi x?><r></r>`)
+	om := NewOrderedMap()
+	om.Set("r", nil)
+	om.Set("?pi", "x")
+	if res, err := encode(om); err != nil || res != `<r></r><?pi x?>` {
+		t.Errorf("ERROR: received %s %v", res, err)
+	}
+	// comments must be well-formed
+	for _, c := range []string{"a--b", "a-", "-->"} {
+		_, err := encode(map[string]any{"r": map[string]any{"#comment": c}})
+		if err == nil || !strings.HasPrefix(err.Error(), "invalid comment") {
+			t.Errorf("ERROR: received %v", err)
+		}
+	}
+}
+
+func Test_Declaration(t *testing.T) {
+	var b bytes.Buffer
+	x := NewEncoder(&b)
+	x.Declaration = true
+	x.Standalone = "yes"
+	err := x.Encode(map[string]any{"?xml": "version=\"1.1\"", "r": 1})
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res := b.String(); res != `<?xml version="1.0" encoding="UTF-8" standalone="yes"?><r>1</r>` {
+		t.Errorf("ERROR: received %s", res)
+	}
+	b.Reset()
+	x = NewEncoder(&b)
+	x.Declaration = true
+	x.Indent = "  "
+	x.Version = "1.1"
+	x.Encoding = "ISO-8859-1"
+	err = x.Encode(map[string]any{"r": map[string]any{"e": 1}})
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res := b.String(); res != "<?xml version=\"1.1\" encoding=\"ISO-8859-1\"?>\n<r>\n  <e>1</e>\n</r>" {
+		t.Errorf("ERROR: received %s", res)
+	}
+	// decoded declaration, with the output encoding
+	b.Reset()
+	x = NewEncoder(&b)
+	err = x.Encode(map[string]any{"?xml": `version="1.0" encoding='ISO-8859-1' standalone="no"`, "r": "é"})
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res := b.String(); res != `<?xml version="1.0" encoding="UTF-8" standalone="no"?><r>é</r>` {
+		t.Errorf("ERROR: received %s", res)
+	}
+}
+
+func testProlog(t *testing.T, src string, rjson string, rxml string) {
+	t.Logf("")
+	t.Logf("xml => json: %s => %s\n", src, rjson)
+	x := NewDecoder(strings.NewReader(src))
+	x.Comments = true
+	x.ProcInsts = true
+	x.Directives = true
+	x.Ordered = true
+	var v any
+	err := x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	res := Stringify(v)
+	if res != rjson {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+	// json round-trip
+	v, err = ToJson([]byte(res))
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	t.Logf("json => xml: %s => %s\n", rjson, rxml)
+	res, err = encode(v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res != rxml {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
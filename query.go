@@ -0,0 +1,480 @@
+package xqml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query is a compiled path expression, selecting values in decoded documents.
+//
+// The syntax is a subset of XPath:
+//
+//	/r/e       child elements, the leading "/" being optional
+//	//e        descendant elements, at any depth
+//	*          any child element
+//	e[2]       second e child of each parent, 1-based, or e[last()] for the last one
+//	@id        attribute, or @* for all attributes
+//	#text      text of elements, text() being also supported
+//	.          current element
+//	e[@id='3'] elements matching a predicate, also supporting !=, child paths like [a/b='x'],
+//	           text like [.='x'] or [#text='x'], and existence tests like [@id]
+//
+// Names can be quoted, like 'http://ns:e', when they contain special characters.
+// Repeated elements stored as lists are selected as multiple values, so that queries work the same
+// whether an element was collapsed to a single value or not.
+// Values are compared as text, so [@id=3] matches both "3" and 3.
+type Query struct {
+	expr  string
+	steps []*step
+	conv  Convention
+}
+
+const (
+	stepChild = iota
+	stepAttr
+	stepText
+	stepSelf
+)
+
+// step is a query step, selecting values from each value of the previous step.
+type step struct {
+	kind  int
+	name  string
+	deep  bool
+	preds []*predicate
+}
+
+// predicate filters the values selected by a step, by index or by condition.
+type predicate struct {
+	// index is the 1-based index, -1 being last(), or 0 for a condition
+	index int
+	steps []*step
+	op    string
+	value string
+}
+
+// CompileQuery compiles a query expression, using the default Xqml convention.
+func CompileQuery(expr string) (*Query, error) {
+	steps, err := parseSteps(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query '%s': %w", expr, err)
+	}
+	return &Query{expr: expr, steps: steps, conv: Xqml}, nil
+}
+
+// MustCompileQuery is like CompileQuery but panics if the expression is invalid.
+func MustCompileQuery(expr string) *Query {
+	q, err := CompileQuery(expr)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// Select returns the values matching a query expression in a decoded value.
+func Select(value any, expr string) ([]any, error) {
+	q, err := CompileQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	return q.Select(value), nil
+}
+
+// SetConvention sets the attributes and text keys used by the query, which must match the decoder ones.
+func (q *Query) SetConvention(c Convention) {
+	q.conv = c
+}
+
+// String returns the query expression.
+func (q *Query) String() string {
+	return q.expr
+}
+
+// Select returns the values matching the query, in document order.
+// The value is usually a decoded document, like {"r":{"e":[1,2]}}, being a map[string]any or an *OrderedMap.
+func (q *Query) Select(value any) []any {
+	return q.eval(q.steps, value)
+}
+
+// First returns the first value matching the query, and whether one was found.
+func (q *Query) First(value any) (any, bool) {
+	res := q.Select(value)
+	if len(res) == 0 {
+		return nil, false
+	}
+	return res[0], true
+}
+
+func (q *Query) eval(steps []*step, value any) []any {
+	nodes := []any{value}
+	for _, s := range steps {
+		var res []any
+		for _, n := range nodes {
+			if s.deep {
+				q.walk(n, func(d any) {
+					res = append(res, q.apply(s, d)...)
+				})
+			} else {
+				res = append(res, q.apply(s, n)...)
+			}
+		}
+		nodes = res
+	}
+	return nodes
+}
+
+// walk calls fn for a value and all its descendant elements, in document order.
+func (q *Query) walk(value any, fn func(value any)) {
+	fn(value)
+	for _, e := range q.elements(value) {
+		for _, v := range nodeValues(e.value) {
+			q.walk(v, fn)
+		}
+	}
+}
+
+// apply returns the values selected by a step from a value, filtered by the step predicates.
+func (q *Query) apply(s *step, value any) []any {
+	var res []any
+	switch s.kind {
+	case stepSelf:
+		res = []any{value}
+	case stepChild:
+		for _, e := range q.elements(value) {
+			if s.name == "*" || e.name == s.name {
+				res = append(res, nodeValues(e.value)...)
+			}
+		}
+	case stepAttr:
+		for _, a := range q.attributes(value) {
+			if s.name == "*" || a.name == s.name {
+				res = append(res, a.value)
+			}
+		}
+	case stepText:
+		res = q.texts(value)
+	}
+	for _, p := range s.preds {
+		res = q.filter(p, res)
+	}
+	return res
+}
+
+func (q *Query) filter(p *predicate, values []any) []any {
+	if p.index != 0 {
+		i := p.index - 1
+		if p.index < 0 {
+			i = len(values) - 1
+		}
+		if i < 0 || i >= len(values) {
+			return nil
+		}
+		return []any{values[i]}
+	}
+	var res []any
+	for _, v := range values {
+		if q.match(p, v) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// match returns true if any value selected by the predicate path matches the condition.
+func (q *Query) match(p *predicate, value any) bool {
+	for _, v := range q.eval(p.steps, value) {
+		switch p.op {
+		case "":
+			return true
+		case "=":
+			if q.text(v) == p.value {
+				return true
+			}
+		case "!=":
+			if q.text(v) != p.value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// elements returns the child elements of a value, including the ones stored in mixed content,
+// comments, processing instructions, directives and CDATA sections being left out.
+func (q *Query) elements(value any) []*tag {
+	entries, isMap := mapEntries(value)
+	if !isMap {
+		return nil
+	}
+	var res []*tag
+	for _, e := range entries {
+		switch {
+		case e.name == MixedKey:
+			mixed, _ := e.value.([]any)
+			for _, m := range mixed {
+				segment, _ := mapEntries(m)
+				for _, s := range segment {
+					if !isMisc(s.name) {
+						res = append(res, s)
+					}
+				}
+			}
+		case q.isAttr(e), e.name == q.conv.TextKey, e.name == CDataKey, isMisc(e.name):
+		default:
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// attributes returns the attributes of a value, without prefix.
+func (q *Query) attributes(value any) []*tag {
+	entries, isMap := mapEntries(value)
+	if !isMap {
+		return nil
+	}
+	var res []*tag
+	for _, e := range entries {
+		if q.conv.AttrKey != "" && e.name == q.conv.AttrKey {
+			attrs, _ := mapEntries(e.value)
+			res = append(res, attrs...)
+		} else if q.isAttr(e) {
+			res = append(res, &tag{e.name[len(q.conv.AttrPrefix):], e.value})
+		}
+	}
+	return res
+}
+
+// isAttr returns true if a map entry is an attribute, like Encoder does.
+func (q *Query) isAttr(e *tag) bool {
+	if q.conv.AttrKey != "" {
+		return e.name == q.conv.AttrKey
+	}
+	if q.conv.AttrPrefix != "" {
+		return strings.HasPrefix(e.name, q.conv.AttrPrefix)
+	}
+	return isScalar(e.value) && e.name != q.conv.TextKey && e.name != MixedKey
+}
+
+// texts returns the text of a value, being the value itself for scalars, or the text parts of mixed content.
+func (q *Query) texts(value any) []any {
+	if isScalar(value) {
+		return []any{value}
+	}
+	entries, _ := mapEntries(value)
+	for _, e := range entries {
+		if e.name == q.conv.TextKey && e.value != nil {
+			return []any{e.value}
+		}
+	}
+	var res []any
+	for _, e := range entries {
+		if e.name == MixedKey {
+			mixed, _ := e.value.([]any)
+			for _, m := range mixed {
+				if text, isText := m.(string); isText && strings.Trim(text, " \n\r\t") != "" {
+					res = append(res, text)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// text returns the text of a value used in predicates comparisons.
+func (q *Query) text(value any) string {
+	texts := q.texts(value)
+	parts := make([]string, len(texts))
+	for i, t := range texts {
+		parts[i] = fmt.Sprintf("%v", t)
+	}
+	return strings.Join(parts, "")
+}
+
+// nodeValues returns the values of an element, being multiple values for repeated elements.
+func nodeValues(value any) []any {
+	if list, isList := value.([]any); isList {
+		return list
+	}
+	return []any{value}
+}
+
+// parseSteps parses a query expression into steps.
+func parseSteps(expr string) ([]*step, error) {
+	s := strings.TrimSpace(expr)
+	if s == "" {
+		return nil, fmt.Errorf("empty query")
+	}
+	var steps []*step
+	deep := false
+	if strings.HasPrefix(s, "//") {
+		deep = true
+		s = s[2:]
+	} else if strings.HasPrefix(s, "/") {
+		s = s[1:]
+	}
+	for {
+		st, rest, err := parseStep(s)
+		if err != nil {
+			return nil, err
+		}
+		st.deep = deep
+		steps = append(steps, st)
+		if rest == "" {
+			return steps, nil
+		}
+		if strings.HasPrefix(rest, "//") {
+			deep = true
+			s = rest[2:]
+		} else if strings.HasPrefix(rest, "/") {
+			deep = false
+			s = rest[1:]
+		} else {
+			return nil, fmt.Errorf("unexpected '%s'", rest)
+		}
+	}
+}
+
+// parseStep parses a step and its predicates, returning the remaining expression.
+func parseStep(s string) (*step, string, error) {
+	st := &step{kind: stepChild}
+	if strings.HasPrefix(s, "@") {
+		st.kind = stepAttr
+		s = s[1:]
+	}
+	name, s, err := parseName(s)
+	if err != nil {
+		return nil, "", err
+	}
+	if st.kind == stepChild {
+		switch name {
+		case ".":
+			st.kind = stepSelf
+		case "#text", "text()":
+			st.kind = stepText
+		}
+	}
+	st.name = name
+	for strings.HasPrefix(s, "[") {
+		end := closingBracket(s)
+		if end < 0 {
+			return nil, "", fmt.Errorf("missing ']'")
+		}
+		p, err := parsePredicate(strings.TrimSpace(s[1:end]))
+		if err != nil {
+			return nil, "", err
+		}
+		st.preds = append(st.preds, p)
+		s = s[end+1:]
+	}
+	return st, s, nil
+}
+
+// parseName parses a name, which can be quoted, returning the remaining expression.
+func parseName(s string) (string, string, error) {
+	if s == "" {
+		return "", "", fmt.Errorf("missing name")
+	}
+	if s[0] == '\'' || s[0] == '"' {
+		end := strings.IndexByte(s[1:], s[0])
+		if end < 0 {
+			return "", "", fmt.Errorf("missing quote")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+	if s[0] == '*' {
+		return "*", s[1:], nil
+	}
+	end := strings.IndexAny(s, "/[]@*='\"! \t")
+	if end < 0 {
+		end = len(s)
+	}
+	if end == 0 {
+		return "", "", fmt.Errorf("missing name")
+	}
+	return s[:end], s[end:], nil
+}
+
+// closingBracket returns the index of the bracket closing the one at index 0, ignoring quoted text.
+func closingBracket(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parsePredicate parses a predicate content, like "2", "last()", "@id='3'" or "@id".
+func parsePredicate(s string) (*predicate, error) {
+	if s == "last()" {
+		return &predicate{index: -1}, nil
+	}
+	if i, err := strconv.Atoi(s); err == nil {
+		if i < 1 {
+			return nil, fmt.Errorf("invalid index %d, must be 1 or more", i)
+		}
+		return &predicate{index: i}, nil
+	}
+	p := &predicate{}
+	path := s
+	if i, op := findOperator(s); i >= 0 {
+		p.op = op
+		path = strings.TrimSpace(s[:i])
+		value := strings.TrimSpace(s[i+len(op):])
+		if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		} else if value == "" {
+			return nil, fmt.Errorf("missing value in predicate '%s'", s)
+		}
+		p.value = value
+	}
+	steps, err := parseSteps(path)
+	if err != nil {
+		return nil, err
+	}
+	p.steps = steps
+	return p, nil
+}
+
+// findOperator returns the index of the first "=" or "!=" operator which is not quoted or in a nested predicate.
+func findOperator(s string) (int, string) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth == 0 && c == '=':
+			return i, "="
+		case depth == 0 && c == '!' && i+1 < len(s) && s[i+1] == '=':
+			return i, "!="
+		}
+	}
+	return -1, ""
+}
@@ -0,0 +1,107 @@
+package xqml
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Query(t *testing.T) {
+	src := `<r><e id="1">a</e><e id="2"><n>b</n><n>c</n></e><x><e id="3">d</e></x></r>`
+	testQuery(t, src, Xqml, "/r/e", `[{"#text":"a","@id":"1"},{"@id":"2","n":["b","c"]}]`)
+	testQuery(t, src, Xqml, "r/e/@id", `["1","2"]`)
+	testQuery(t, src, Xqml, "//e/@id", `["1","2","3"]`)
+	testQuery(t, src, Xqml, "//e[@id='3']/#text", `["d"]`)
+	testQuery(t, src, Xqml, "//e[@id=2]/n[2]", `["c"]`)
+	testQuery(t, src, Xqml, "//e[@id!='1']/@id", `["2","3"]`)
+	testQuery(t, src, Xqml, "/r/e[n]/@id", `["2"]`)
+	testQuery(t, src, Xqml, "/r/e[n='c']/@id", `["2"]`)
+	testQuery(t, src, Xqml, "//e[.='a']/@id", `["1"]`)
+	testQuery(t, src, Xqml, "/r/*/e/text()", `["d"]`)
+	testQuery(t, src, Xqml, "//n[last()]", `["c"]`)
+	testQuery(t, src, Xqml, "//e[1]/@*", `["1","3"]`)
+	testQuery(t, src, Xqml, "/r/y", `null`)
+	// collapsed values
+	testQuery(t, `<r><e>1</e></r>`, Xqml, "/r/e[1]/#text", `[1]`)
+	testQuery(t, `<r><e/><e>2</e></r>`, Xqml, "/r/e", `[null,2]`)
+	// conventions
+	testQuery(t, `<r><e id="1">a</e></r>`, BadgerFish, "/r/e[@id=1]/#text", `["a"]`)
+	testQuery(t, `<r><e id="1">a</e></r>`, Abdera, "/r/e[@id=1]/#text", `["a"]`)
+	testQuery(t, `<r><e id="1">a</e></r>`, GData, "/r/e/@id", `["1"]`)
+	// namespaces
+	testQuery(t, `<r xmlns:a="urn:a"><a:e>1</a:e></r>`, Xqml, "/r/'urn:a:e'", `[1]`)
+}
+
+func Test_QueryMixed(t *testing.T) {
+	x := NewDecoder(strings.NewReader(`<r>a<b>1</b>c<b>2</b></r>`))
+	x.Mixed = true
+	x.Ordered = true
+	var v any
+	if err := x.Decode(&v); err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	res, err := Select(v, "//b")
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if s := Stringify(res); s != `[1,2]` {
+		t.Errorf("ERROR: received %s", s)
+	}
+	res, _ = Select(v, "/r/#text")
+	if s := Stringify(res); s != `["a","c"]` {
+		t.Errorf("ERROR: received %s", s)
+	}
+}
+
+func Test_QueryMisc(t *testing.T) {
+	// comments, processing instructions and CDATA sections are not elements
+	for _, ordered := range []bool{false, true} {
+		x := NewDecoder(strings.NewReader(`<r><!--c--><e>1</e><?pi x?><f><![CDATA[x]]></f><e>2</e></r>`))
+		x.Comments = true
+		x.ProcInsts = true
+		x.CData = true
+		x.Ordered = ordered
+		var v any
+		if err := x.Decode(&v); err != nil {
+			t.Errorf("ERROR: %v", err)
+		}
+		expected := `[1,2,{"#cdata":"x"}]`
+		if ordered {
+			expected = `[1,{"#cdata":"x"},2]`
+		}
+		for _, expr := range []string{"/r/*", "//*/*"} {
+			res, _ := Select(v, expr)
+			if s := Stringify(res); s != expected {
+				t.Errorf("ERROR: received %s for %s", s, expr)
+			}
+		}
+	}
+}
+
+func Test_QueryErrors(t *testing.T) {
+	for _, expr := range []string{"", "/r/", "r[", "r[0]", "r[@id=]", "r/'e", "r]"} {
+		if _, err := CompileQuery(expr); err == nil {
+			t.Errorf("ERROR: expected error for '%s'", expr)
+		}
+	}
+}
+
+func testQuery(t *testing.T, src string, conv Convention, expr string, rjson string) {
+	t.Logf("")
+	t.Logf("query %s: %s => %s\n", expr, src, rjson)
+	x := NewDecoder(strings.NewReader(src))
+	x.SetConvention(conv)
+	var v any
+	err := x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	q, err := CompileQuery(expr)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	q.SetConvention(conv)
+	if res := Stringify(q.Select(v)); res != rjson {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
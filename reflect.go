@@ -0,0 +1,78 @@
+package xqml
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// field describes a struct field and its xqml tag.
+//
+// The tag name follows xqml conventions: "x" for an element, "@x" for an attribute,
+// "#text" for the element text and "a.b" for a dotted path of elements.
+// It can be followed by options, like `xqml:"name,omitempty"`. Use "-" to ignore a field.
+type field struct {
+	index     []int
+	name      string
+	path      []string
+	tagged    bool
+	omitEmpty bool
+}
+
+var fieldCache sync.Map
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// typeFields returns the fields of struct type t, including fields of embedded structs.
+func typeFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	fields := appendFields(nil, t, nil)
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+func appendFields(fields []field, t reflect.Type, index []int) []field {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, tagged := sf.Tag.Lookup("xqml")
+		if tag == "-" {
+			continue
+		}
+		idx := make([]int, len(index)+1)
+		copy(idx, index)
+		idx[len(index)] = i
+		// inline embedded structs without tags
+		if sf.Anonymous && !tagged && sf.Type.Kind() == reflect.Struct {
+			fields = appendFields(fields, sf.Type, idx)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		// parse tag
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = sf.Name
+			tagged = false
+		}
+		f := field{
+			index:  idx,
+			name:   name,
+			path:   strings.Split(name, "."),
+			tagged: tagged,
+		}
+		for _, opt := range strings.Split(opts, ",") {
+			if opt == "omitempty" {
+				f.omitEmpty = true
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
@@ -0,0 +1,68 @@
+package xqml
+
+import (
+	"fmt"
+	"io"
+)
+
+// StreamFunc is the type of the function called by Stream() for each matching element.
+// The path is the dotted path of the element, and value is the element as Decode() would return it.
+// Returning an error stops the parsing, and the error is returned by Stream().
+type StreamFunc func(path string, value any) error
+
+// Stream reads the next XML-encoded value from its input
+// and calls fn for each element matching ItemDepth or ItemPath,
+// as soon as the element end is read.
+//
+// Once fn returns, the element is removed from the document,
+// so memory stays bounded by the size of a single element.
+// When Partials is true, Stream() can be called until io.EOF is reached.
+// Errors found while reading the input are returned as *DecodeError, and errors of fn are returned as is.
+func (x *Decoder) Stream(fn StreamFunc) error {
+	// check streaming is configured
+	if x.ItemDepth <= 0 && len(x.ItemPath) == 0 {
+		return fmt.Errorf("invalid stream, ItemDepth or ItemPath must be set")
+	}
+	// initialize
+	err := x.init()
+	if err != nil {
+		return err
+	}
+	x.raw = false
+	x.setConvention()
+	x.newValidation()
+	x.elements = 0
+	x.schemaLists = make(map[string]bool)
+	x.stream = fn
+	defer func() { x.stream = nil }()
+	// parse input
+	curr := elem{data: x.newNode(), content: ContentObject}
+	err = x.parse(&curr, nil)
+	if err != nil {
+		return err
+	}
+	// return
+	if x.Partials && curr.count == 0 {
+		return io.EOF
+	}
+	return x.validation.result()
+}
+
+// isItem returns true if the element must be streamed.
+func (x *Decoder) isItem(item *elem) bool {
+	if x.ItemDepth > 0 && item.depth == x.ItemDepth {
+		return true
+	}
+	return x.itemPath[item.name] || x.itemPath[item.path]
+}
+
+// streamValue calls the stream function with the element value, then removes it from its parent.
+func (x *Decoder) streamValue(parent *elem, item *elem) error {
+	value := x.getValue(parent, item.name)
+	err := x.stream(item.path, value)
+	if err != nil {
+		return err
+	}
+	x.removeValue(parent, item.name)
+	return nil
+}
@@ -0,0 +1,78 @@
+package xqml
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_Stream(t *testing.T) {
+	src := `<r><e>1</e><e x="2"><f>3</f></e><g>4</g></r>`
+	// depth
+	testStream(t, src, 2, nil, nil, []string{`r.e 1`, `r.e {"@x":"2","f":3}`, `r.g 4`})
+	testStream(t, src, 1, nil, nil, []string{`r {"e":[1,{"@x":"2","f":3}],"g":4}`})
+	testStream(t, src, 3, nil, nil, []string{`r.e.f 3`})
+	// path
+	testStream(t, src, 0, []string{"r.e"}, nil, []string{`r.e 1`, `r.e {"@x":"2","f":3}`})
+	testStream(t, src, 0, []string{"f,g"}, nil, []string{`r.e.f 3`, `r.g 4`})
+	// force list
+	testStream(t, src, 0, []string{"r.e"}, []string{"e"}, []string{`r.e 1`, `r.e {"@x":"2","f":3}`})
+}
+
+func Test_StreamPartials(t *testing.T) {
+	reader := strings.NewReader(`<r><e>1</e></r><r><e>2</e><e>3</e></r>`)
+	x := NewDecoder(reader)
+	x.Partials = true
+	x.ItemPath = []string{"r.e"}
+	var res []string
+	for {
+		err := x.Stream(func(path string, value any) error {
+			res = append(res, Stringify(value))
+			return nil
+		})
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ERROR: %v", err)
+		}
+	}
+	if strings.Join(res, ",") != "1,2,3" {
+		t.Errorf("ERROR: received %v", res)
+	}
+}
+
+func Test_StreamStop(t *testing.T) {
+	stop := fmt.Errorf("stop")
+	x := NewDecoder(strings.NewReader(`<r><e>1</e><e>2</e></r>`))
+	x.ItemDepth = 2
+	count := 0
+	err := x.Stream(func(path string, value any) error {
+		count++
+		return stop
+	})
+	if err != stop || count != 1 {
+		t.Errorf("ERROR: received %v after %d items", err, count)
+	}
+}
+
+func testStream(t *testing.T, src string, depth int, itemPath []string, forceList []string, expected []string) {
+	t.Logf("")
+	t.Logf("xml => items: %s => %v\n", src, expected)
+	x := NewDecoder(strings.NewReader(src))
+	x.ItemDepth = depth
+	x.ItemPath = itemPath
+	x.ForceList = forceList
+	var res []string
+	err := x.Stream(func(path string, value any) error {
+		res = append(res, path+" "+Stringify(value))
+		return nil
+	})
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if strings.Join(res, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("ERROR: received %v\n", res)
+	}
+}
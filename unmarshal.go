@@ -0,0 +1,283 @@
+package xqml
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// unmarshal stores the decoded value into rv, using xqml struct tags for structs.
+// Values are expected to be uncasted, so casting is done according to the target type.
+func (x *Decoder) unmarshal(value any, rv reflect.Value, path string) error {
+	// allocate pointers
+	if rv.Kind() == reflect.Pointer {
+		if value == nil {
+			return nil
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return x.unmarshal(value, rv.Elem(), path)
+	}
+	// use text unmarshaler when available
+	if rv.CanAddr() && rv.Addr().Type().Implements(textUnmarshalerType) {
+		text, ok := textValue(value)
+		if !ok {
+			return nil
+		}
+		err := rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+		if err != nil {
+			return fmt.Errorf("cannot decode '%s' into %s: %w", path, rv.Type(), err)
+		}
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			return fmt.Errorf("cannot decode '%s' into %s", path, rv.Type())
+		}
+		if value == nil {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		if x.Cast {
+			value = x.castTree(value)
+		}
+		rv.Set(reflect.ValueOf(value))
+		return nil
+	case reflect.Struct:
+		return x.unmarshalStruct(value, rv, path)
+	case reflect.Map:
+		return x.unmarshalMap(value, rv, path)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			if text, ok := textValue(value); ok {
+				rv.SetBytes([]byte(text))
+			}
+			return nil
+		}
+		list := listValue(value)
+		slice := reflect.MakeSlice(rv.Type(), len(list), len(list))
+		for i, item := range list {
+			err := x.unmarshal(item, slice.Index(i), path)
+			if err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+		return nil
+	case reflect.Array:
+		list := listValue(value)
+		for i := 0; i < rv.Len() && i < len(list); i++ {
+			err := x.unmarshal(list[i], rv.Index(i), path)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// scalar values
+	text, ok := textValue(value)
+	if !ok {
+		return nil
+	}
+	return unmarshalScalar(text, rv, path)
+}
+
+func (x *Decoder) unmarshalStruct(value any, rv reflect.Value, path string) error {
+	if value == nil {
+		return nil
+	}
+	for _, f := range typeFields(rv.Type()) {
+		v, ok := lookupField(value, &f)
+		if !ok {
+			continue
+		}
+		err := x.unmarshal(v, fieldByIndex(rv, f.index), newPath(path, f.name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *Decoder) unmarshalMap(value any, rv reflect.Value, path string) error {
+	if value == nil {
+		return nil
+	}
+	if rv.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("cannot decode '%s' into %s", path, rv.Type())
+	}
+	m, isMap := value.(map[string]any)
+	if !isMap {
+		if _, isSlice := value.([]any); isSlice {
+			return fmt.Errorf("cannot decode '%s' into %s", path, rv.Type())
+		}
+		m = map[string]any{"#text": value}
+	}
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMapWithSize(rv.Type(), len(m)))
+	}
+	for k, v := range m {
+		item := reflect.New(rv.Type().Elem()).Elem()
+		err := x.unmarshal(v, item, newPath(path, k))
+		if err != nil {
+			return err
+		}
+		rv.SetMapIndex(reflect.ValueOf(k).Convert(rv.Type().Key()), item)
+	}
+	return nil
+}
+
+func unmarshalScalar(text string, rv reflect.Value, path string) error {
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(text)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return fmt.Errorf("cannot decode '%s' into %s: %w", path, rv.Type(), err)
+		}
+		rv.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(strings.TrimSpace(text), 10, rv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot decode '%s' into %s: %w", path, rv.Type(), err)
+		}
+		rv.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(strings.TrimSpace(text), 10, rv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot decode '%s' into %s: %w", path, rv.Type(), err)
+		}
+		rv.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), rv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot decode '%s' into %s: %w", path, rv.Type(), err)
+		}
+		rv.SetFloat(f)
+		return nil
+	}
+	return fmt.Errorf("cannot decode '%s' into %s", path, rv.Type())
+}
+
+// castTree casts all text values of a decoded value, leaving attributes unchanged.
+func (x *Decoder) castTree(value any) any {
+	switch v := value.(type) {
+	case string:
+		return x.castAuto(v)
+	case map[string]any:
+		for k, e := range v {
+			if !strings.HasPrefix(k, "@") {
+				v[k] = x.castTree(e)
+			}
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = x.castTree(e)
+		}
+	}
+	return value
+}
+
+// lookupField returns the value of a struct field from a decoded element.
+func lookupField(value any, f *field) (any, bool) {
+	v, ok := lookupPath(value, f.path)
+	if ok || f.tagged {
+		return v, ok
+	}
+	// untagged fields are also matched case insensitively
+	if m, isMap := value.(map[string]any); isMap {
+		for k, v := range m {
+			if strings.EqualFold(k, f.name) {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// lookupPath returns the value found at path in a decoded element.
+// Slices found along the path are flattened, and "#text" of a scalar is the scalar itself.
+func lookupPath(value any, path []string) (any, bool) {
+	if len(path) == 0 {
+		return value, true
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		e, ok := v[path[0]]
+		if !ok {
+			return nil, false
+		}
+		return lookupPath(e, path[1:])
+	case []any:
+		var res []any
+		found := false
+		for _, item := range v {
+			if e, ok := lookupPath(item, path); ok {
+				found = true
+				res = append(res, listValue(e)...)
+			}
+		}
+		return res, found
+	case nil:
+		return nil, false
+	default:
+		if len(path) == 1 && path[0] == "#text" {
+			return value, true
+		}
+		return nil, false
+	}
+}
+
+// listValue returns the value as a slice, an empty slice for nil and a single item slice for other values.
+func listValue(value any) []any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	default:
+		return []any{v}
+	}
+}
+
+// textValue returns the text of a scalar value, or of the "#text" of an element.
+func textValue(value any) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case map[string]any:
+		return textValue(v["#text"])
+	case []any:
+		if len(v) == 0 {
+			return "", false
+		}
+		return textValue(v[0])
+	default:
+		return fmt.Sprintf("%v", v), true
+	}
+}
+
+// fieldByIndex returns the nested field of a struct, allocating embedded pointers when needed.
+func fieldByIndex(rv reflect.Value, index []int) reflect.Value {
+	for i, idx := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(idx)
+	}
+	return rv
+}
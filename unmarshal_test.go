@@ -0,0 +1,92 @@
+package xqml
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type testItem struct {
+	Id    int      `xqml:"@id"`
+	Name  string   `xqml:"name"`
+	Tags  []string `xqml:"tags.tag"`
+	Price float64  `xqml:"price"`
+}
+
+type testFeed struct {
+	Version string            `xqml:"@version"`
+	Title   string            `xqml:"title"`
+	Lang    string            `xqml:"title.@lang"`
+	Zip     string            `xqml:"zip"`
+	Enabled bool              `xqml:"enabled"`
+	Count   *uint8            `xqml:"count"`
+	Items   []testItem        `xqml:"item"`
+	Extra   map[string]string `xqml:"extra"`
+	Other   any               `xqml:"other"`
+	Date    time.Time         `xqml:"date"`
+	Ignored string            `xqml:"-"`
+	Note    string
+}
+
+func Test_Unmarshal(t *testing.T) {
+	src := `<feed version="2">
+		<title lang="en">News</title>
+		<zip>01234</zip>
+		<enabled>True</enabled>
+		<count>7</count>
+		<item id="1"><name>a</name><tags><tag>x</tag></tags><price>1.5</price></item>
+		<extra><a>1</a><b>2</b></extra>
+		<other><c>3</c></other>
+		<date>2023-01-02T03:04:05Z</date>
+		<Ignored>no</Ignored>
+		<note>yes</note>
+	</feed>`
+	var feed testFeed
+	err := NewDecoder(strings.NewReader(src)).Decode(&feed)
+	if err != nil {
+		t.Fatalf("ERROR: %v", err)
+	}
+	res := Stringify(feed)
+	expected := `{"Version":"2","Title":"News","Lang":"en","Zip":"01234","Enabled":true,"Count":7,"Items":[{"Id":1,"Name":"a","Tags":["x"],"Price":1.5}],"Extra":{"a":"1","b":"2"},"Other":{"c":3},"Date":"2023-01-02T03:04:05Z","Ignored":"","Note":"yes"}`
+	if res != expected {
+		t.Errorf("ERROR: received %s", res)
+	}
+}
+
+func Test_UnmarshalOthers(t *testing.T) {
+	// root content
+	var list []int
+	testUnmarshal(t, `<r>1</r>`, &list, `[1]`)
+	var e struct{ E []int }
+	testUnmarshal(t, `<r><e>1</e><e>2</e></r>`, &e, `{"E":[1,2]}`)
+	var m map[string]string
+	testUnmarshal(t, `<r x="1"><e>1</e></r>`, &m, `{"@x":"1","e":"1"}`)
+	var i int
+	testUnmarshal(t, `<r>42</r>`, &i, `42`)
+	var f struct {
+		Text string `xqml:"#text"`
+		X    bool   `xqml:"@x"`
+	}
+	testUnmarshal(t, `<r x="1">text</r>`, &f, `{"Text":"text","X":true}`)
+	testUnmarshal(t, `<r>other</r>`, &f, `{"Text":"other","X":true}`)
+	// errors
+	if err := NewDecoder(strings.NewReader(`<r>a</r>`)).Decode(&i); err == nil {
+		t.Errorf("ERROR: expected error")
+	}
+	if err := NewDecoder(strings.NewReader(`<r>a</r>`)).Decode(i); err == nil {
+		t.Errorf("ERROR: expected error")
+	}
+}
+
+func testUnmarshal(t *testing.T, src string, v any, expected string) {
+	t.Logf("")
+	t.Logf("xml => go: %s => %s\n", src, expected)
+	err := NewDecoder(strings.NewReader(src)).Decode(v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	res := Stringify(v)
+	if res != expected {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
@@ -0,0 +1,499 @@
+package xqml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const xsiURL = "http://www.w3.org/2001/XMLSchema-instance"
+
+// ValidationError is a violation of a Schema, found at an element or attribute path.
+type ValidationError struct {
+	// Path is the dotted path of the element, like "r.x", or of the attribute, like "r.x.@a".
+	Path string
+	// Line and Column are the position of the element start, 1-based.
+	Line   int
+	Column int
+	// Message describes the violation.
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Path, e.Message)
+}
+
+// ValidationErrors are all the violations found in a document, in document order.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Validator validates documents against a Schema.
+//
+// It can be used alone with Validate, or set in Decoder.Validator to validate documents
+// while decoding them, with the same token stream.
+//
+// Elements and attributes are checked against their declarations: unexpected or missing elements and attributes,
+// number of occurrences, order of elements in sequences, exclusivity of choices, text content and values of simple types
+// with their facets. Elements matching a wildcard or of xs:anyType are not validated.
+type Validator struct {
+	// Schema is the schema to validate against.
+	Schema *Schema
+	// FailFast allows to stop on the first violation, returning a *ValidationError.
+	// Default is false, all violations being returned as ValidationErrors.
+	FailFast bool
+}
+
+// NewValidator returns a new validator collecting all violations.
+func NewValidator(schema *Schema) *Validator {
+	return &Validator{Schema: schema}
+}
+
+// Validate reads a document and returns its violations, nil if it is valid.
+// The error is a *ValidationError with FailFast, or ValidationErrors, or any error reading the document.
+func (v *Validator) Validate(reader io.Reader) error {
+	x := NewDecoder(reader)
+	x.Validator = v
+	var value any
+	return x.Decode(&value)
+}
+
+// validation is the validation state of a document being decoded.
+type validation struct {
+	validator *Validator
+	schema    *Schema
+	frames    []*validationFrame
+	errors    ValidationErrors
+	// line and column are the position of the current token
+	line   int
+	column int
+}
+
+// validationFrame is the validation state of an element.
+type validationFrame struct {
+	decl   *schemaElement
+	path   string
+	skip   bool
+	nil    bool
+	counts map[string]int
+	// states are the states of the content model, unordered is true if an element was not expected at all,
+	// and skipped is the first element expected after missing elements, reported if they are not reported as missing
+	states    []int
+	unordered bool
+	skipped   *ValidationError
+	text      strings.Builder
+	elems     bool
+	line      int
+	column    int
+}
+
+func newValidation(v *Validator) *validation {
+	return &validation{validator: v, schema: v.Schema, frames: []*validationFrame{{}}}
+}
+
+// start validates an element start and its attributes.
+func (v *validation) start(name *xml.Name, path string, attrs []xml.Attr) error {
+	parent := v.frames[len(v.frames)-1]
+	frame := &validationFrame{path: path, skip: parent.skip, line: v.line, column: v.column}
+	v.frames = append(v.frames, frame)
+	if frame.skip {
+		return nil
+	}
+	parent.elems = true
+	// find declaration
+	if parent.decl == nil {
+		decl, ok := v.schema.elements[name.Local]
+		if !ok {
+			frame.skip = true
+			return v.error(path, "unexpected root element '%s'", name.Local)
+		}
+		frame.decl = v.schema.element(decl)
+	} else {
+		child, ok := parent.decl.children[name.Local]
+		switch {
+		case ok:
+			frame.decl = v.schema.element(child.decl)
+			parent.counts[name.Local]++
+			if n := parent.counts[name.Local]; child.max >= 0 && n > child.max {
+				err := v.error(path, "too many elements '%s', maximum is %d", name.Local, child.max)
+				if err != nil {
+					return err
+				}
+			} else if err := v.sequence(parent, name.Local, path); err != nil {
+				return err
+			}
+		case parent.decl.content == contentAny || parent.decl.anyChildren:
+			frame.skip = true
+			return v.sequence(parent, name.Local, path)
+		case parent.decl.content == contentSimple:
+			frame.skip = true
+			return v.error(path, "unexpected element '%s', text only is expected", name.Local)
+		default:
+			frame.skip = true
+			return v.error(path, "unexpected element '%s'", name.Local)
+		}
+	}
+	frame.counts = make(map[string]int)
+	if frame.decl.model != nil {
+		frame.states = frame.decl.model.start()
+	}
+	return v.attributes(frame, attrs)
+}
+
+// sequence checks that an element is expected at its position in the content model of its parent.
+// An element expected after missing elements is checked from its position,
+// and an unexpected element is ignored, the next elements being checked from the same position.
+func (v *validation) sequence(parent *validationFrame, name string, path string) error {
+	model := parent.decl.model
+	if model == nil {
+		return nil
+	}
+	if states := model.next(parent.states, name); len(states) > 0 {
+		parent.states = states
+		return nil
+	}
+	expected := model.expected(parent.states)
+	if states := model.next(model.reachable(parent.states), name); len(states) > 0 {
+		if parent.skipped == nil {
+			msg := fmt.Sprintf("unexpected element '%s', expected '%s'", name, strings.Join(expected, "', '"))
+			parent.skipped = &ValidationError{Path: path, Line: v.line, Column: v.column, Message: msg}
+		}
+		parent.states = states
+		return nil
+	}
+	parent.unordered = true
+	if len(expected) == 0 {
+		return v.error(path, "unexpected element '%s', no more elements are expected", name)
+	}
+	return v.error(path, "unexpected element '%s', expected '%s'", name, strings.Join(expected, "', '"))
+}
+
+// attributes validates the attributes of an element.
+func (v *validation) attributes(frame *validationFrame, attrs []xml.Attr) error {
+	decl := frame.decl
+	found := make(map[string]bool)
+	for _, attr := range attrs {
+		switch {
+		case attr.Name.Space == xmlnsPrefix || (attr.Name.Space == "" && attr.Name.Local == xmlnsPrefix):
+			continue
+		case attr.Name.Space == xsiURL:
+			if attr.Name.Local == "nil" && attr.Value == "true" {
+				frame.nil = true
+			}
+			continue
+		case attr.Name.Space == xmlURL:
+			continue
+		}
+		found[attr.Name.Local] = true
+		var a *schemaAttribute
+		for _, d := range decl.attrs {
+			if d.name == attr.Name.Local {
+				a = d
+				break
+			}
+		}
+		path := frame.path + ".@" + attr.Name.Local
+		if a == nil {
+			if decl.anyAttrs || decl.content == contentAny {
+				continue
+			}
+			err := v.error(path, "unexpected attribute '%s'", attr.Name.Local)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if msg := v.schema.validateValue(a.simple, attr.Value); msg != "" {
+			err := v.error(path, "%s", msg)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, a := range decl.attrs {
+		if a.required && !found[a.name] {
+			err := v.error(frame.path, "missing attribute '%s'", a.name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// text adds text to the current element.
+func (v *validation) text(text string) {
+	frame := v.frames[len(v.frames)-1]
+	if frame.decl != nil && !frame.skip {
+		frame.text.WriteString(text)
+	}
+}
+
+// end validates the content of the current element.
+func (v *validation) end() error {
+	frame := v.frames[len(v.frames)-1]
+	v.frames = v.frames[:len(v.frames)-1]
+	decl := frame.decl
+	if frame.skip || decl == nil {
+		return nil
+	}
+	// report content errors at the element start
+	v.line, v.column = frame.line, frame.column
+	text := frame.text.String()
+	blank := strings.Trim(text, " \n\r\t") == ""
+	if frame.nil {
+		if frame.elems || !blank {
+			return v.error(frame.path, "nil element must be empty")
+		}
+		return nil
+	}
+	var err error
+	switch decl.content {
+	case contentSimple:
+		if msg := v.schema.validateValue(decl.simple, text); msg != "" && !frame.elems {
+			err = v.error(frame.path, "%s", msg)
+		}
+	case contentElements:
+		if !blank {
+			err = v.error(frame.path, "unexpected text, elements only are expected")
+		}
+	}
+	count := len(v.errors)
+	for _, name := range decl.order {
+		if err != nil {
+			return err
+		}
+		child := decl.children[name]
+		switch n := frame.counts[name]; {
+		case n >= child.min:
+		case child.min == 1:
+			err = v.error(frame.path, "missing element '%s'", name)
+		default:
+			err = v.error(frame.path, "missing elements '%s', minimum is %d", name, child.min)
+		}
+	}
+	// elements missing in the content model, like one of a choice, when not already reported
+	if err != nil || len(v.errors) > count {
+		return err
+	}
+	if frame.skipped != nil {
+		v.line, v.column = frame.skipped.Line, frame.skipped.Column
+		return v.error(frame.skipped.Path, "%s", frame.skipped.Message)
+	}
+	if model := decl.model; model != nil && !frame.unordered && !model.accepts(frame.states) {
+		expected := model.expected(frame.states)
+		if len(expected) == 1 {
+			return v.error(frame.path, "missing element '%s'", expected[0])
+		}
+		return v.error(frame.path, "missing element, expected one of '%s'", strings.Join(expected, "', '"))
+	}
+	return err
+}
+
+// error adds a violation, returning it with FailFast.
+func (v *validation) error(path string, format string, args ...any) error {
+	err := &ValidationError{Path: path, Line: v.line, Column: v.column, Message: fmt.Sprintf(format, args...)}
+	if v.validator.FailFast {
+		return err
+	}
+	v.errors = append(v.errors, err)
+	return nil
+}
+
+// result returns the collected violations, or nil.
+func (v *validation) result() error {
+	if v == nil || len(v.errors) == 0 {
+		return nil
+	}
+	return v.errors
+}
+
+var (
+	integerRegexp  = regexp.MustCompile(`^[+-]?[0-9]+$`)
+	floatRegexp    = regexp.MustCompile(`^([+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?|-?INF|NaN)$`)
+	dateRegexp     = regexp.MustCompile(`^-?[0-9]{4,}-[0-9]{2}-[0-9]{2}(Z|[+-][0-9]{2}:[0-9]{2})?$`)
+	timeRegexp     = regexp.MustCompile(`^[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?(Z|[+-][0-9]{2}:[0-9]{2})?$`)
+	dateTimeRegexp = regexp.MustCompile(`^-?[0-9]{4,}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?(Z|[+-][0-9]{2}:[0-9]{2})?$`)
+	durationRegexp = regexp.MustCompile(`^-?P([0-9]+Y)?([0-9]+M)?([0-9]+D)?(T([0-9]+H)?([0-9]+M)?([0-9]+(\.[0-9]+)?S)?)?$`)
+	// patterns is the cache of compiled pattern facets, nil for invalid patterns
+	patterns sync.Map
+)
+
+// integerRanges are the ranges of bounded integer built-in types.
+var integerRanges = map[string][2]int64{
+	"byte":          {-1 << 7, 1<<7 - 1},
+	"short":         {-1 << 15, 1<<15 - 1},
+	"int":           {-1 << 31, 1<<31 - 1},
+	"unsignedByte":  {0, 1<<8 - 1},
+	"unsignedShort": {0, 1<<16 - 1},
+	"unsignedInt":   {0, 1<<32 - 1},
+}
+
+// validateValue returns a message if a value is not valid for a simple type, or "".
+func (s *Schema) validateValue(t *simpleType, value string) string {
+	if t == nil {
+		return ""
+	}
+	if t.builtin != "string" && t.builtin != "anySimpleType" {
+		value = strings.Trim(value, " \n\r\t")
+	}
+	if !validBuiltin(t.builtin, value) {
+		return fmt.Sprintf("invalid %s value '%s'", t.builtin, value)
+	}
+	for _, r := range t.facets {
+		if msg := validateFacets(r, value); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// validBuiltin returns true if a value is valid for a built-in type, unknown types being always valid.
+func validBuiltin(builtin string, value string) bool {
+	switch builtin {
+	case "boolean":
+		return value == "true" || value == "false" || value == "1" || value == "0"
+	case "integer":
+		return integerRegexp.MatchString(value)
+	case "nonNegativeInteger", "positiveInteger", "nonPositiveInteger", "negativeInteger":
+		if !integerRegexp.MatchString(value) {
+			return false
+		}
+		zero := strings.Trim(value, "+-0") == ""
+		negative := strings.HasPrefix(value, "-") && !zero
+		switch builtin {
+		case "nonNegativeInteger":
+			return !negative
+		case "positiveInteger":
+			return !negative && !zero
+		case "nonPositiveInteger":
+			return negative || zero
+		default:
+			return negative
+		}
+	case "long":
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	case "unsignedLong":
+		_, err := strconv.ParseUint(strings.TrimPrefix(value, "+"), 10, 64)
+		return err == nil
+	case "byte", "short", "int", "unsignedByte", "unsignedShort", "unsignedInt":
+		i, err := strconv.ParseInt(value, 10, 64)
+		r := integerRanges[builtin]
+		return err == nil && i >= r[0] && i <= r[1]
+	case "decimal":
+		return decimalRegexp.MatchString(value)
+	case "float", "double":
+		return floatRegexp.MatchString(value)
+	case "date":
+		return dateRegexp.MatchString(value)
+	case "time":
+		return timeRegexp.MatchString(value)
+	case "dateTime":
+		return dateTimeRegexp.MatchString(value)
+	case "duration":
+		return durationRegexp.MatchString(value) && value != "P" && !strings.HasSuffix(value, "T")
+	}
+	return true
+}
+
+// validateFacets returns a message if a value does not match the facets of a restriction, or "".
+func validateFacets(r *xsdRestriction, value string) string {
+	if len(r.Enumerations) > 0 {
+		values := make([]string, len(r.Enumerations))
+		found := false
+		for i, e := range r.Enumerations {
+			values[i] = e.Value
+			found = found || e.Value == value
+		}
+		if !found {
+			sort.Strings(values)
+			return fmt.Sprintf("invalid value '%s', must be one of '%s'", value, strings.Join(values, "', '"))
+		}
+	}
+	if len(r.Patterns) > 0 {
+		matched := false
+		for _, p := range r.Patterns {
+			re := compilePattern(p.Value)
+			matched = matched || re == nil || re.MatchString(value)
+		}
+		if !matched {
+			return fmt.Sprintf("invalid value '%s', must match pattern '%s'", value, r.Patterns[0].Value)
+		}
+	}
+	length := utf8.RuneCountInString(value)
+	if n, ok := facetInt(r.Length); ok && length != n {
+		return fmt.Sprintf("invalid value '%s', length must be %d", value, n)
+	}
+	if n, ok := facetInt(r.MinLength); ok && length < n {
+		return fmt.Sprintf("invalid value '%s', length must be at least %d", value, n)
+	}
+	if n, ok := facetInt(r.MaxLength); ok && length > n {
+		return fmt.Sprintf("invalid value '%s', length must be at most %d", value, n)
+	}
+	if f := r.MinInclusive; f != nil && compareValues(value, f.Value) < 0 {
+		return fmt.Sprintf("invalid value '%s', must be at least %s", value, f.Value)
+	}
+	if f := r.MaxInclusive; f != nil && compareValues(value, f.Value) > 0 {
+		return fmt.Sprintf("invalid value '%s', must be at most %s", value, f.Value)
+	}
+	if f := r.MinExclusive; f != nil && compareValues(value, f.Value) <= 0 {
+		return fmt.Sprintf("invalid value '%s', must be more than %s", value, f.Value)
+	}
+	if f := r.MaxExclusive; f != nil && compareValues(value, f.Value) >= 0 {
+		return fmt.Sprintf("invalid value '%s', must be less than %s", value, f.Value)
+	}
+	return ""
+}
+
+// compilePattern returns an anchored regular expression for a pattern facet, or nil if it is not supported.
+func compilePattern(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		re = nil
+	}
+	patterns.Store(pattern, re)
+	return re
+}
+
+func facetInt(f *xsdFacet) (int, bool) {
+	if f == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(f.Value)
+	return n, err == nil
+}
+
+// compareValues compares two values as numbers, or as strings if one is not a number, like dates.
+func compareValues(a string, b string) int {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	}
+	return 0
+}
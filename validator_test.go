@@ -0,0 +1,164 @@
+package xqml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testValidatorXsd = `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="r">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="id" type="xs:positiveInteger"/>
+        <xs:element name="code" type="code" minOccurs="0"/>
+        <xs:element name="e" maxOccurs="2">
+          <xs:complexType>
+            <xs:simpleContent>
+              <xs:extension base="xs:boolean">
+                <xs:attribute name="n" type="xs:byte" use="required"/>
+              </xs:extension>
+            </xs:simpleContent>
+          </xs:complexType>
+        </xs:element>
+        <xs:element name="color" minOccurs="0">
+          <xs:simpleType>
+            <xs:restriction base="xs:string">
+              <xs:enumeration value="red"/>
+              <xs:enumeration value="blue"/>
+            </xs:restriction>
+          </xs:simpleType>
+        </xs:element>
+        <xs:element name="any" minOccurs="0">
+          <xs:complexType>
+            <xs:sequence>
+              <xs:any processContents="skip" maxOccurs="unbounded"/>
+            </xs:sequence>
+          </xs:complexType>
+        </xs:element>
+      </xs:sequence>
+    </xs:complexType>
+  </xs:element>
+  <xs:simpleType name="code">
+    <xs:restriction base="xs:string">
+      <xs:pattern value="[A-Z]{2}[0-9]+"/>
+      <xs:maxLength value="5"/>
+    </xs:restriction>
+  </xs:simpleType>
+</xs:schema>`
+
+func Test_Validator(t *testing.T) {
+	schema, err := LoadSchema(strings.NewReader(testValidatorXsd))
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	v := NewValidator(schema)
+	testValidator(t, v, `<r><id>1</id><code>AB12</code><e n="1">true</e><color>red</color><any><x y="1"/></any></r>`, ``)
+	testValidator(t, v, `<r>
+  <id>0</id>
+  <code>AB1234</code>
+  <e n="200">yes</e>
+  <e>1</e>
+  <e n="1" m="2">0</e>
+  <color>green</color>
+  <x/>
+</r>`, `2:3: r.id: invalid positiveInteger value '0'
+3:3: r.code: invalid value 'AB1234', length must be at most 5
+4:3: r.e.@n: invalid byte value '200'
+4:3: r.e: invalid boolean value 'yes'
+5:3: r.e: missing attribute 'n'
+6:3: r.e: too many elements 'e', maximum is 2
+6:3: r.e.@m: unexpected attribute 'm'
+7:3: r.color: invalid value 'green', must be one of 'blue', 'red'
+8:3: r.x: unexpected element 'x'`)
+	testValidator(t, v, `<r><code>ab</code><e n="1">1<b/></e>text</r>`, `1:4: r.code: invalid value 'ab', must match pattern '[A-Z]{2}[0-9]+'
+1:29: r.e.b: unexpected element 'b', text only is expected
+1:1: r: unexpected text, elements only are expected
+1:1: r: missing element 'id'`)
+	testValidator(t, v, `<x/>`, `1:1: x: unexpected root element 'x'`)
+	// fail fast
+	v.FailFast = true
+	err = v.Validate(strings.NewReader(`<r><id>x</id><e/></r>`))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Path != "r.id" || verr.Line != 1 || verr.Column != 4 {
+		t.Errorf("ERROR: received %v", err)
+	}
+	// decoder
+	x := NewDecoder(strings.NewReader(`<r><id>1</id><e n="1">true</e><e n="2">1</e></r>`))
+	x.Validator = NewValidator(schema)
+	x.Schema = schema
+	var value any
+	err = x.Decode(&value)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res := Stringify(value); res != `{"r":{"e":[{"#text":true,"@n":1},{"#text":true,"@n":2}],"id":1}}` {
+		t.Errorf("ERROR: received %s", res)
+	}
+}
+
+const testContentModelXsd = `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="r">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="a" type="xs:string"/>
+        <xs:choice>
+          <xs:element name="b" type="xs:string"/>
+          <xs:element name="c" type="xs:string"/>
+        </xs:choice>
+        <xs:group ref="g" maxOccurs="2"/>
+        <xs:element name="s">
+          <xs:complexType>
+            <xs:all>
+              <xs:element name="x" type="xs:string"/>
+              <xs:element name="y" type="xs:string" minOccurs="0"/>
+            </xs:all>
+          </xs:complexType>
+        </xs:element>
+      </xs:sequence>
+    </xs:complexType>
+  </xs:element>
+  <xs:group name="g">
+    <xs:sequence>
+      <xs:element name="d" type="xs:string"/>
+      <xs:element name="e" type="xs:string" minOccurs="0"/>
+    </xs:sequence>
+  </xs:group>
+</xs:schema>`
+
+func Test_ValidatorContentModel(t *testing.T) {
+	schema, err := LoadSchema(strings.NewReader(testContentModelXsd))
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	v := NewValidator(schema)
+	testValidator(t, v, `<r><a/><b/><d/><e/><d/><s><y/><x/></s></r>`, ``)
+	testValidator(t, v, `<r><a/><c/><d/><s><x/></s></r>`, ``)
+	// sequence order
+	testValidator(t, v, `<r><b/><a/><d/><s><x/></s></r>`, `1:8: r.a: unexpected element 'a', expected 'd'
+1:4: r.b: unexpected element 'b', expected 'a'`)
+	testValidator(t, v, `<r><a/><b/><d/><s><x/></s><d/></r>`, `1:27: r.d: unexpected element 'd', no more elements are expected`)
+	testValidator(t, v, `<r><a/><b/><d/><e/><e/><s><x/></s></r>`, `1:20: r.e: unexpected element 'e', expected 'd', 's'`)
+	// choice exclusivity
+	testValidator(t, v, `<r><a/><b/><c/><d/><s><x/></s></r>`, `1:12: r.c: unexpected element 'c', expected 'd'`)
+	testValidator(t, v, `<r><a/><d/><s><x/></s></r>`, `1:8: r.d: unexpected element 'd', expected 'b', 'c'`)
+	testValidator(t, v, `<r><a/><b/><d/><s/></r>`, `1:16: r.s: missing element 'x'`)
+	// missing elements are reported once
+	testValidator(t, v, `<r><b/><d/><s><x/></s></r>`, `1:1: r: missing element 'a'`)
+	testValidator(t, v, `<r><a/><b/><d/></r>`, `1:1: r: missing element 's'`)
+}
+
+func testValidator(t *testing.T, v *Validator, src string, rerr string) {
+	t.Logf("")
+	t.Logf("validate: %s => %s\n", src, rerr)
+	err := v.Validate(strings.NewReader(src))
+	res := ""
+	if err != nil {
+		res = err.Error()
+	}
+	if res != rerr {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
@@ -0,0 +1,68 @@
+package xqml
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+const (
+	// WhitespaceTrim trims leading and trailing whitespace, dropping whitespace only text.
+	WhitespaceTrim = "trim"
+	// WhitespacePreserve keeps text as is, dropping whitespace only text between child elements.
+	WhitespacePreserve = "preserve"
+	// WhitespaceCollapse replaces runs of whitespace by a single space, then trims leading and trailing whitespace.
+	WhitespaceCollapse = "collapse"
+)
+
+// newWhitespaceRules parses whitespace rules like "r.pre=preserve", also supporting multiple comma separated rules.
+func newWhitespaceRules(rules []string) (*pathRules, error) {
+	return newPathRules(rules, "whitespace", WhitespaceTrim, WhitespacePreserve, WhitespaceCollapse)
+}
+
+// whitespace returns the whitespace mode of an element: the one of WhitespaceRules, then of xml:space if XmlSpace is true,
+// then the one of its parent element, the root element using Whitespace.
+func (x *Decoder) whitespace(parent *elem, e *xml.StartElement, path string, name string) string {
+	if mode := x.whitespaceRules.find(path, name); mode != "" {
+		return mode
+	}
+	if x.XmlSpace {
+		for _, attr := range e.Attr {
+			if (attr.Name.Space == xmlURL || attr.Name.Space == xmlPrefix) && attr.Name.Local == "space" {
+				switch attr.Value {
+				case "preserve":
+					return WhitespacePreserve
+				case "default":
+					return x.Whitespace
+				}
+			}
+		}
+	}
+	if parent.space != "" {
+		return parent.space
+	}
+	return x.Whitespace
+}
+
+// normalize returns the text of an element according to its whitespace mode.
+// Whitespace only text of preserved elements is returned empty, and kept as pending in case the element has no children.
+func (curr *elem) normalize(text string) string {
+	switch curr.space {
+	case WhitespacePreserve:
+		if strings.Trim(text, " \n\r\t") == "" {
+			curr.blank += text
+			return ""
+		}
+		text = curr.blank + text
+		curr.blank = ""
+		return text
+	case WhitespaceCollapse:
+		return strings.Join(strings.FieldsFunc(text, isSpace), " ")
+	default:
+		return strings.Trim(text, " \n\r\t")
+	}
+}
+
+// isSpace returns true for XML whitespace characters.
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\n' || r == '\r' || r == '\t'
+}
@@ -0,0 +1,52 @@
+package xqml
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Whitespace(t *testing.T) {
+	// trim
+	testWhitespace(t, `<r><s> a  b </s><t> </t></r>`, WhitespaceTrim, nil, false, `{"r":{"s":"a  b","t":null}}`)
+	// preserve
+	testWhitespace(t, `<r> <s> a  b </s><t> </t> </r>`, WhitespacePreserve, nil, false, `{"r":{"s":" a  b ","t":" "}}`)
+	testWhitespace(t, `<r><s> 1 </s><s>1</s></r>`, WhitespacePreserve, nil, false, `{"r":{"s":[" 1 ",1]}}`)
+	testWhitespace(t, `<r><s> a<!--c--> b </s></r>`, WhitespacePreserve, nil, false, `{"r":{"s":" a b "}}`)
+	testWhitespace(t, `<r><s> a<!--c-->  </s></r>`, WhitespacePreserve, nil, false, `{"r":{"s":" a  "}}`)
+	testWhitespace(t, `<r x="1">  </r>`, WhitespacePreserve, nil, false, `{"r":{"#text":"  ","@x":"1"}}`)
+	// collapse
+	testWhitespace(t, `<r><s> a
+	 b </s><t> </t></r>`, WhitespaceCollapse, nil, false, `{"r":{"s":"a b","t":null}}`)
+	// rules, inherited by children
+	testWhitespace(t, `<r><s> a </s><pre> <b> b </b> </pre></r>`, "", []string{"pre=preserve"}, false, `{"r":{"pre":{"b":" b "},"s":"a"}}`)
+	testWhitespace(t, `<r><s> a  b </s><t> a  b </t></r>`, WhitespacePreserve, []string{"r.t=collapse,s=trim"}, false, `{"r":{"s":"a  b","t":"a b"}}`)
+	testWhitespace(t, `<r><p><s> a </s></p><q><s> a </s></q></r>`, "", []string{"*.p=preserve"}, false, `{"r":{"p":{"s":" a "},"q":{"s":"a"}}}`)
+	// xml:space
+	testWhitespace(t, `<r><s xml:space="preserve"> a </s><t> a </t></r>`, "", nil, true, `{"r":{"s":{"#text":" a ","@http://www.w3.org/XML/1998/namespace:space":"preserve"},"t":"a"}}`)
+	testWhitespace(t, `<r xml:space="preserve"><s> a </s><t xml:space="default"> a </t></r>`, "", nil, true, `{"r":{"@http://www.w3.org/XML/1998/namespace:space":"preserve","s":" a ","t":{"#text":"a","@http://www.w3.org/XML/1998/namespace:space":"default"}}}`)
+	testWhitespace(t, `<r><s xml:space="preserve"> a </s></r>`, "", nil, false, `{"r":{"s":{"#text":"a","@http://www.w3.org/XML/1998/namespace:space":"preserve"}}}`)
+	testWhitespace(t, `<r><s xml:space="preserve"> a </s></r>`, "", []string{"s=trim"}, true, `{"r":{"s":{"#text":"a","@http://www.w3.org/XML/1998/namespace:space":"preserve"}}}`)
+	// errors
+	testWhitespace(t, `<r/>`, "keep", nil, false, `invalid whitespace mode 'keep'`)
+	testWhitespace(t, `<r/>`, "", []string{"s=keep"}, false, `invalid whitespace rule 's=keep', unknown type 'keep'`)
+}
+
+func testWhitespace(t *testing.T, src string, whitespace string, rules []string, xmlSpace bool, rjson string) {
+	t.Logf("")
+	t.Logf("xml => json: %s => %s\n", src, rjson)
+	x := NewDecoder(strings.NewReader(src))
+	x.Whitespace = whitespace
+	x.WhitespaceRules = rules
+	x.XmlSpace = xmlSpace
+	var v any
+	err := x.Decode(&v)
+	res := ""
+	if err != nil {
+		res = err.Error()
+	} else {
+		res = Stringify(v)
+	}
+	if res != rjson {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}
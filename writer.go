@@ -3,7 +3,7 @@ package xqml
 import (
 	"encoding/xml"
 	"fmt"
-	"sort"
+	"io"
 	"strings"
 )
 
@@ -15,19 +15,23 @@ type tag struct {
 var emptyAttrs []xml.Attr
 
 func (x *Encoder) write(value any) error {
+	// convert Go values to generic values
+	value, err := x.generic(value)
+	if err != nil {
+		return err
+	}
 	switch value.(type) {
-	case map[string]any:
-		// count number of elements
-		m := value.(map[string]any)
+	case map[string]any, *OrderedMap:
+		entries, _ := mapEntries(value)
+		content := x.newContent(entries)
+		// count number of elements, ignoring comments, processing instructions and directives
 		c := 0
-		d := false
-		key := ""
-		for k := range m {
-			if !strings.HasPrefix(k, "@") && k != "#text" {
-				key = k
-				c = c + 1
-			} else {
-				d = true
+		d := content.data
+		var value2 any
+		for _, e := range content.elems {
+			if !isMisc(e.name) {
+				c++
+				value2 = e.value
 			}
 		}
 		if c == 0 {
@@ -35,7 +39,6 @@ func (x *Encoder) write(value any) error {
 		} else if c == 1 && d {
 			return x.writeAny(map[string]any{x.Root: value}, "")
 		} else if c == 1 {
-			value2 := m[key]
 			switch value2.(type) {
 			case []any:
 				return x.writeAny(map[string]any{x.Root: value}, "")
@@ -54,9 +57,13 @@ func (x *Encoder) write(value any) error {
 }
 
 func (x *Encoder) writeAny(value any, parent string) error {
+	if isMisc(parent) {
+		return x.writeMisc(value, parent)
+	}
 	switch value.(type) {
-	case map[string]any:
-		return x.writeMap(value.(map[string]any), parent)
+	case map[string]any, *OrderedMap:
+		entries, _ := mapEntries(value)
+		return x.writeMap(entries, parent)
 	case []any:
 		v := value.([]any)
 		return x.writeSlice(&v, parent)
@@ -65,51 +72,102 @@ func (x *Encoder) writeAny(value any, parent string) error {
 	}
 }
 
-func (x *Encoder) writeMap(value map[string]any, parent string) error {
-	var err error
-	var attrs []*tag
-	var elems []*tag
-	var text any
-	//var text any
-	for k, v := range value {
-		if strings.HasPrefix(k, "@") {
-			attrs = append(attrs, &tag{k[1:], v})
-		} else if k == "#text" {
-			text = v
+// content is the content of an element, split into attributes, text, mixed content and elements.
+type content struct {
+	attrs []*tag
+	elems []*tag
+	text  any
+	cdata any
+	mixed []any
+	// data is true when attributes, text or mixed content keys are present
+	data bool
+}
+
+// newContent splits map entries into attributes, text, mixed content and elements.
+func (x *Encoder) newContent(entries []*tag) *content {
+	c := &content{}
+	for _, e := range entries {
+		if x.AttrKey != "" && e.name == x.AttrKey {
+			if attrs, isMap := mapEntries(e.value); isMap {
+				c.attrs = append(c.attrs, attrs...)
+			}
+			c.data = true
+		} else if x.AttrPrefix != "" && strings.HasPrefix(e.name, x.AttrPrefix) {
+			c.attrs = append(c.attrs, &tag{e.name[len(x.AttrPrefix):], e.value})
+			c.data = true
+		} else if e.name == x.TextKey {
+			c.text = e.value
+			c.data = true
+		} else if e.name == CDataKey {
+			c.cdata = e.value
+			c.data = true
+		} else if e.name == MixedKey {
+			c.mixed, _ = e.value.([]any)
+			c.data = true
+		} else if isMisc(e.name) {
+			c.elems = append(c.elems, e)
+		} else if x.AttrPrefix == "" && x.AttrKey == "" && isScalar(e.value) {
+			c.attrs = append(c.attrs, e)
+			c.data = true
 		} else {
-			elems = append(elems, &tag{k, v})
+			c.elems = append(c.elems, e)
 		}
 	}
-	// remove root unexpected values
+	return c
+}
+
+// isScalar returns true if the value is neither nil, a map or a slice.
+func isScalar(value any) bool {
+	switch value.(type) {
+	case nil, map[string]any, *OrderedMap, []any:
+		return false
+	}
+	return true
+}
+
+// writeMap writes an element from its entries, which are already sorted by mapEntries.
+func (x *Encoder) writeMap(entries []*tag, parent string) error {
+	var err error
+	content := x.newContent(entries)
+	attrs := content.attrs
+	elems := content.elems
+	text := content.text
+	cdata := content.cdata
+	mixed := content.mixed
+	// remove root unexpected values, and write the XML declaration first
 	if parent == "" {
 		attrs = nil
+		elems = x.prolog(elems)
 	}
-	// sorts tags and elems
-	sort.Slice(attrs, func(i, j int) bool {
-		return strings.Compare(attrs[i].name, attrs[j].name) < 0
-	})
-	sort.Slice(elems, func(i, j int) bool {
-		return strings.Compare(elems[i].name, elems[j].name) < 0
-	})
 	// start
-	name := xml.Name{Local: parent}
+	var end xml.EndElement
+	var ns int
 	if parent != "" {
-		start := xml.StartElement{
-			Name: name,
-			Attr: *newAttrs(attrs),
+		end, ns, err = x.startElement(parent, attrs)
+		if err != nil {
+			return err
+		}
+		if x.isVoid(end) {
+			return x.endElement(end, ns)
 		}
-		err = x.encoder.EncodeToken(start)
+		err = x.writeText(text)
 		if err != nil {
 			return err
 		}
-		if text != nil {
-			cdata := xml.CharData(fmt.Sprintf("%v", text))
-			err = x.encoder.EncodeToken(cdata)
+		if cdata != nil {
+			err = x.writeCData(fmt.Sprintf("%v", cdata))
 			if err != nil {
 				return err
 			}
 		}
 	}
+	// mixed content
+	for _, m := range mixed {
+		err = x.writeSegment(m)
+		if err != nil {
+			return err
+		}
+	}
 	// content
 	for _, e := range elems {
 		err = x.writeAny(e.value, e.name)
@@ -119,14 +177,83 @@ func (x *Encoder) writeMap(value map[string]any, parent string) error {
 	}
 	// end
 	if parent != "" {
-		end := xml.EndElement{Name: name}
-		err = x.encoder.EncodeToken(end)
+		err = x.endElement(end, ns)
 		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// writeSegment writes a mixed content segment, being a text or a map of elements.
+func (x *Encoder) writeSegment(value any) error {
+	entries, isMap := mapEntries(value)
+	if !isMap {
+		return x.writeText(value)
+	}
+	for _, e := range entries {
+		err := x.writeAny(e.value, e.name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prolog returns the document entries, with the "?xml" declaration first and its encoding set to Encoding,
+// or without it if Declaration is true or Encoding is not UTF-8, as the declaration is already written.
+func (x *Encoder) prolog(elems []*tag) []*tag {
+	for i, e := range elems {
+		if e.name == ProcInstPrefix+xmlPrefix {
+			res := make([]*tag, 0, len(elems))
+			if !x.Declaration && x.output == nil {
+				res = append(res, &tag{e.name, x.prologDeclaration(e.value)})
+			}
+			res = append(res, elems[:i]...)
+			return append(res, elems[i+1:]...)
+		}
+	}
+	return elems
+}
+
+// writeMisc writes a comment, processing instruction or directive, or a list of them.
+func (x *Encoder) writeMisc(value any, name string) error {
+	if list, isList := value.([]any); isList {
+		return x.writeSlice(&list, name)
+	}
+	text := ""
+	if value != nil {
+		text = fmt.Sprintf("%v", value)
+	}
+	var token xml.Token
+	var kind string
+	switch {
+	case name == CommentKey:
+		// xml.Encoder only rejects "-->", which is not enough for well-formed comments
+		if strings.Contains(text, "--") || strings.HasSuffix(text, "-") {
+			return fmt.Errorf("invalid comment '%s', comments can't contain '--' nor end with '-'", text)
+		}
+		token = xml.Comment(text)
+		kind = "comment"
+	case strings.HasPrefix(name, ProcInstPrefix):
+		token = xml.ProcInst{Target: name[len(ProcInstPrefix):], Inst: []byte(text)}
+		kind = "processing instruction"
+	default:
+		directive := strings.ToUpper(name[len(DirectivePrefix):])
+		if text != "" {
+			directive += " " + text
+		}
+		token = xml.Directive(directive)
+		kind = "directive"
+	}
+	// character references are not allowed in comments, processing instructions and directives
+	err := x.checkEncodable(kind, text)
+	if err != nil {
+		return err
+	}
+	return x.encoder.EncodeToken(token)
+}
+
 func (x *Encoder) writeSlice(value *[]any, parent string) error {
 	for _, a := range *value {
 		err := x.writeAny(a, parent)
@@ -138,23 +265,18 @@ func (x *Encoder) writeSlice(value *[]any, parent string) error {
 }
 
 func (x *Encoder) writeValue(value any, parent string) error {
-	name := xml.Name{Local: parent}
-	start := xml.StartElement{
-		Name: name,
-		Attr: nil,
-	}
-	err := x.encoder.EncodeToken(start)
+	end, ns, err := x.startElement(parent, nil)
 	if err != nil {
 		return err
 	}
+	if x.isVoid(end) {
+		return x.endElement(end, ns)
+	}
 	err = x.writeText(value)
 	if err != nil {
 		return err
 	}
-	end := xml.EndElement{
-		Name: name,
-	}
-	err = x.encoder.EncodeToken(end)
+	err = x.endElement(end, ns)
 	if err != nil {
 		return err
 	}
@@ -165,8 +287,48 @@ func (x *Encoder) writeText(value any) error {
 	if value == nil {
 		return nil
 	}
-	cdata := xml.CharData(fmt.Sprintf("%v", value))
-	return x.encoder.EncodeToken(cdata)
+	text := fmt.Sprintf("%v", value)
+	if n := len(x.paths); n > 0 && len(x.cdataPaths) > 0 {
+		path := x.paths[n-1]
+		if x.cdataPaths[path] || x.cdataPaths[path[strings.LastIndexByte(path, '.')+1:]] {
+			return x.writeCData(text)
+		}
+	}
+	return x.encoder.EncodeToken(xml.CharData(text))
+}
+
+// writeCData writes a text as a CDATA section, splitting it around "]]>" markers,
+// and around characters the output encoding does not support, which are written as character references.
+// As xml.Encoder has no CDATA token, the section is written directly after flushing the encoder.
+func (x *Encoder) writeCData(text string) error {
+	err := x.encoder.Flush()
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	open := false
+	for _, r := range strings.ReplaceAll(text, "]]>", "]]]]><![CDATA[>") {
+		switch {
+		case !x.encodable(r) && open:
+			fmt.Fprintf(&b, "]]>&#%d;", r)
+			open = false
+		case !x.encodable(r):
+			fmt.Fprintf(&b, "&#%d;", r)
+		case !open:
+			b.WriteString("<![CDATA[")
+			b.WriteRune(r)
+			open = true
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if open {
+		b.WriteString("]]>")
+	} else if text == "" {
+		b.WriteString("<![CDATA[]]>")
+	}
+	_, err = io.WriteString(x.writer, b.String())
+	return err
 }
 
 func newAttrs(attrs []*tag) *[]xml.Attr {
@@ -175,9 +337,13 @@ func newAttrs(attrs []*tag) *[]xml.Attr {
 	}
 	res := make([]xml.Attr, len(attrs))
 	for i, attr := range attrs {
+		value := ""
+		if attr.value != nil {
+			value = fmt.Sprintf("%v", attr.value)
+		}
 		res[i] = xml.Attr{
 			Name:  xml.Name{Local: attr.name},
-			Value: attr.value.(string),
+			Value: value,
 		}
 	}
 	return &res
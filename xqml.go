@@ -1,6 +1,7 @@
 package xqml
 
 import (
+	"bytes"
 	"encoding/json"
 )
 
@@ -17,3 +18,15 @@ func ToJson(b []byte) (map[string]any, error) {
 	}
 	return v, nil
 }
+
+// ToJsonNumber is like ToJson, but reads numbers as json.Number to keep their exact text.
+func ToJsonNumber(b []byte) (map[string]any, error) {
+	var v map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	err := decoder.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -12,7 +12,7 @@ func Test_Parse(t *testing.T) {
 	// root simple
 	testParse(t, `<r>1</r>`, `{"r":1}`, "", "", true, false, nil, false)
 	testParse(t, `<r>1</r>`, `{"r":1}`, "", "", true, true, nil, false)
-	testParse(t, `<n:r>1</n:r>`, `{"n:r":1}`, "", "", true, true, nil, false)
+	testParse(t, `<n:r xmlns:n="urn:n">1</n:r>`, `{"urn:n:r":{"#text":1,"@xmlns:n":"urn:n"}}`, "", "", true, true, nil, false)
 	// root attributes
 	testParse(t, `<r x="1">1</r>`, `{"r":{"#text":1,"@x":"1"}}`, "", "", true, false, nil, false)
 	// level2 simple
@@ -0,0 +1,880 @@
+package xqml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const xsdURL = "http://www.w3.org/2001/XMLSchema"
+
+// Schema is an XML Schema (XSD) driving a Decoder:
+// elements with maxOccurs > 1 are forced to lists, texts and attributes are casted according to their type,
+// and missing attributes with a default or fixed value are added.
+//
+// Elements and types are matched by local name, namespaces being ignored.
+// Only the structure needed for decoding and validation is used: identity constraints are ignored for instance.
+type Schema struct {
+	elements        map[string]*xsdElement
+	complexTypes    map[string]*xsdComplexType
+	simpleTypes     map[string]*xsdSimpleType
+	groups          map[string]*xsdGroup
+	attributeGroups map[string]*xsdAttributeGroup
+	attributes      map[string]*xsdAttribute
+	// xsPrefixes are the prefixes of the XML Schema namespace, "" being the default namespace
+	xsPrefixes map[string]bool
+	// prefixes are the namespace declarations of the schemas
+	prefixes []nsBinding
+	mu       sync.Mutex
+	resolved map[*xsdElement]*schemaElement
+}
+
+// Content types of elements.
+const (
+	// contentAny is any content, for elements without type or of xs:anyType
+	contentAny = iota
+	// contentSimple is text only, of a simple type
+	contentSimple
+	// contentElements is elements only
+	contentElements
+	// contentMixed is text and elements
+	contentMixed
+)
+
+// schemaElement is an element declaration resolved for decoding and validation.
+type schemaElement struct {
+	decl        *xsdElement
+	content     int
+	simple      *simpleType
+	cast        string
+	attrs       []*schemaAttribute
+	anyAttrs    bool
+	children    map[string]*schemaChild
+	order       []string
+	anyChildren bool
+	// particles are the particles of the content model, in sequence, and model is their automaton
+	particles []*schemaParticle
+	model     *contentModel
+}
+
+// schemaChild is a child element of a content model, with its minimum and maximum occurrences, -1 being unbounded.
+// It is a list if it can occur more than once.
+type schemaChild struct {
+	decl *xsdElement
+	min  int
+	max  int
+	list bool
+}
+
+// schemaAttribute is an attribute declaration resolved for decoding and validation.
+type schemaAttribute struct {
+	name       string
+	simple     *simpleType
+	cast       string
+	value      string
+	hasDefault bool
+	required   bool
+}
+
+// simpleType is a simple type, being a built-in type with the facets of its restrictions.
+type simpleType struct {
+	builtin string
+	facets  []*xsdRestriction
+}
+
+type xsdSchema struct {
+	Attrs              []xml.Attr           `xml:",any,attr"`
+	TargetNamespace    string               `xml:"targetNamespace,attr"`
+	ElementFormDefault string               `xml:"elementFormDefault,attr"`
+	Includes           []*xsdInclude        `xml:"include"`
+	Imports            []*xsdInclude        `xml:"import"`
+	Redefines          []*xsdInclude        `xml:"redefine"`
+	Elements           []*xsdElement        `xml:"element"`
+	ComplexTypes       []*xsdComplexType    `xml:"complexType"`
+	SimpleTypes        []*xsdSimpleType     `xml:"simpleType"`
+	Groups             []*xsdGroup          `xml:"group"`
+	AttributeGroups    []*xsdAttributeGroup `xml:"attributeGroup"`
+	Attributes         []*xsdAttribute      `xml:"attribute"`
+}
+
+type xsdInclude struct {
+	SchemaLocation string `xml:"schemaLocation,attr"`
+}
+
+type xsdElement struct {
+	Name        string          `xml:"name,attr"`
+	Ref         string          `xml:"ref,attr"`
+	Type        string          `xml:"type,attr"`
+	MinOccurs   string          `xml:"minOccurs,attr"`
+	MaxOccurs   string          `xml:"maxOccurs,attr"`
+	Default     string          `xml:"default,attr"`
+	Fixed       string          `xml:"fixed,attr"`
+	Nillable    string          `xml:"nillable,attr"`
+	Form        string          `xml:"form,attr"`
+	ComplexType *xsdComplexType `xml:"complexType"`
+	SimpleType  *xsdSimpleType  `xml:"simpleType"`
+	// ns is the namespace of the element in documents
+	ns string
+}
+
+// xsdGroup is a model group: sequence, choice, all, or a named group definition or reference.
+type xsdGroup struct {
+	Name      string
+	Ref       string
+	MinOccurs string
+	MaxOccurs string
+	Elements  []*xsdElement
+	Any       []*xsdAny
+	Sequences []*xsdGroup
+	Choices   []*xsdGroup
+	Alls      []*xsdGroup
+	Groups    []*xsdGroup
+	// kind is the group tag, "sequence", "choice", "all" or "group"
+	kind string
+	// particles are the elements, wildcards and groups, in document order
+	particles []any
+}
+
+type xsdAny struct {
+	MinOccurs string `xml:"minOccurs,attr"`
+	MaxOccurs string `xml:"maxOccurs,attr"`
+}
+
+// UnmarshalXML decodes a model group, keeping the order of its particles.
+func (g *xsdGroup) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	g.kind = start.Name.Local
+	for _, attr := range start.Attr {
+		if attr.Name.Space != "" {
+			continue
+		}
+		switch attr.Name.Local {
+		case "name":
+			g.Name = attr.Value
+		case "ref":
+			g.Ref = attr.Value
+		case "minOccurs":
+			g.MinOccurs = attr.Value
+		case "maxOccurs":
+			g.MaxOccurs = attr.Value
+		}
+	}
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.EndElement:
+			return nil
+		case xml.StartElement:
+			var particle any
+			switch t.Name.Local {
+			case "element":
+				e := &xsdElement{}
+				g.Elements = append(g.Elements, e)
+				particle, err = e, d.DecodeElement(e, &t)
+			case "any":
+				a := &xsdAny{}
+				g.Any = append(g.Any, a)
+				particle, err = a, d.DecodeElement(a, &t)
+			case "sequence", "choice", "all", "group":
+				child := &xsdGroup{}
+				switch t.Name.Local {
+				case "sequence":
+					g.Sequences = append(g.Sequences, child)
+				case "choice":
+					g.Choices = append(g.Choices, child)
+				case "all":
+					g.Alls = append(g.Alls, child)
+				default:
+					g.Groups = append(g.Groups, child)
+				}
+				particle, err = child, d.DecodeElement(child, &t)
+			default:
+				err = d.Skip()
+			}
+			if err != nil {
+				return err
+			}
+			if particle != nil {
+				g.particles = append(g.particles, particle)
+			}
+		}
+	}
+}
+
+// xsdModel is the content model and attributes of a complex type or of a derivation.
+type xsdModel struct {
+	Sequence        *xsdGroup            `xml:"sequence"`
+	Choice          *xsdGroup            `xml:"choice"`
+	All             *xsdGroup            `xml:"all"`
+	Group           *xsdGroup            `xml:"group"`
+	Attributes      []*xsdAttribute      `xml:"attribute"`
+	AttributeGroups []*xsdAttributeGroup `xml:"attributeGroup"`
+	AnyAttribute    *struct{}            `xml:"anyAttribute"`
+}
+
+type xsdComplexType struct {
+	Name           string      `xml:"name,attr"`
+	Mixed          string      `xml:"mixed,attr"`
+	SimpleContent  *xsdContent `xml:"simpleContent"`
+	ComplexContent *xsdContent `xml:"complexContent"`
+	xsdModel
+}
+
+type xsdContent struct {
+	Mixed       string         `xml:"mixed,attr"`
+	Extension   *xsdDerivation `xml:"extension"`
+	Restriction *xsdDerivation `xml:"restriction"`
+}
+
+type xsdDerivation struct {
+	Base string `xml:"base,attr"`
+	xsdModel
+}
+
+type xsdSimpleType struct {
+	Name        string          `xml:"name,attr"`
+	Restriction *xsdRestriction `xml:"restriction"`
+	List        *struct{}       `xml:"list"`
+	Union       *struct{}       `xml:"union"`
+}
+
+type xsdRestriction struct {
+	Base         string         `xml:"base,attr"`
+	SimpleType   *xsdSimpleType `xml:"simpleType"`
+	Enumerations []*xsdFacet    `xml:"enumeration"`
+	Patterns     []*xsdFacet    `xml:"pattern"`
+	Length       *xsdFacet      `xml:"length"`
+	MinLength    *xsdFacet      `xml:"minLength"`
+	MaxLength    *xsdFacet      `xml:"maxLength"`
+	MinInclusive *xsdFacet      `xml:"minInclusive"`
+	MaxInclusive *xsdFacet      `xml:"maxInclusive"`
+	MinExclusive *xsdFacet      `xml:"minExclusive"`
+	MaxExclusive *xsdFacet      `xml:"maxExclusive"`
+}
+
+type xsdFacet struct {
+	Value string `xml:"value,attr"`
+}
+
+type xsdAttribute struct {
+	Name       string         `xml:"name,attr"`
+	Ref        string         `xml:"ref,attr"`
+	Type       string         `xml:"type,attr"`
+	Default    string         `xml:"default,attr"`
+	Fixed      string         `xml:"fixed,attr"`
+	Use        string         `xml:"use,attr"`
+	SimpleType *xsdSimpleType `xml:"simpleType"`
+}
+
+type xsdAttributeGroup struct {
+	Name            string               `xml:"name,attr"`
+	Ref             string               `xml:"ref,attr"`
+	Attributes      []*xsdAttribute      `xml:"attribute"`
+	AttributeGroups []*xsdAttributeGroup `xml:"attributeGroup"`
+	AnyAttribute    *struct{}            `xml:"anyAttribute"`
+}
+
+// xsdTypes are the cast types of XML Schema built-in types, other built-in types being strings.
+var xsdTypes = map[string]string{
+	"boolean":            CastBool,
+	"long":               CastInt,
+	"int":                CastInt,
+	"short":              CastInt,
+	"byte":               CastInt,
+	"unsignedLong":       CastUint,
+	"unsignedInt":        CastUint,
+	"unsignedShort":      CastUint,
+	"unsignedByte":       CastUint,
+	"integer":            CastDecimal,
+	"nonNegativeInteger": CastDecimal,
+	"positiveInteger":    CastDecimal,
+	"nonPositiveInteger": CastDecimal,
+	"negativeInteger":    CastDecimal,
+	"decimal":            CastDecimal,
+	"float":              CastFloat,
+	"double":             CastFloat,
+	"anyType":            "",
+	"anySimpleType":      "",
+}
+
+// LoadSchema reads an XML Schema. Included and imported schemas are not loaded, see LoadSchemaFile.
+func LoadSchema(reader io.Reader) (*Schema, error) {
+	s := newSchema()
+	_, err := s.read(reader)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadSchemaFile reads an XML Schema file, with its included, imported and redefined schemas.
+// Schemas locations are relative to the including file, and remote locations like "http://..." are ignored.
+func LoadSchemaFile(path string) (*Schema, error) {
+	s := newSchema()
+	err := s.readFile(path, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newSchema() *Schema {
+	return &Schema{
+		elements:        make(map[string]*xsdElement),
+		complexTypes:    make(map[string]*xsdComplexType),
+		simpleTypes:     make(map[string]*xsdSimpleType),
+		groups:          make(map[string]*xsdGroup),
+		attributeGroups: make(map[string]*xsdAttributeGroup),
+		attributes:      make(map[string]*xsdAttribute),
+		xsPrefixes:      make(map[string]bool),
+		resolved:        make(map[*xsdElement]*schemaElement),
+	}
+}
+
+func (s *Schema) readFile(path string, seen map[string]bool) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if seen[abs] {
+		return nil
+	}
+	seen[abs] = true
+	file, err := os.Open(abs)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	doc, err := s.read(file)
+	if err != nil {
+		return fmt.Errorf("invalid schema '%s': %w", path, err)
+	}
+	var locations []*xsdInclude
+	locations = append(locations, doc.Includes...)
+	locations = append(locations, doc.Imports...)
+	locations = append(locations, doc.Redefines...)
+	for _, include := range locations {
+		location := include.SchemaLocation
+		if location == "" || strings.Contains(location, "://") {
+			continue
+		}
+		if !filepath.IsAbs(location) {
+			location = filepath.Join(filepath.Dir(abs), location)
+		}
+		err = s.readFile(location, seen)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read reads a schema document and adds its global definitions.
+func (s *Schema) read(reader io.Reader) (*xsdSchema, error) {
+	doc := &xsdSchema{}
+	decoder := xml.NewDecoder(reader)
+	decoder.Strict = false
+	err := decoder.Decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	for _, attr := range doc.Attrs {
+		prefix := ""
+		if attr.Name.Space == xmlnsPrefix {
+			prefix = attr.Name.Local
+		} else if attr.Name.Space != "" || attr.Name.Local != xmlnsPrefix {
+			continue
+		}
+		if attr.Value == xsdURL {
+			s.xsPrefixes[prefix] = true
+		} else {
+			s.prefixes = append(s.prefixes, nsBinding{prefix, attr.Value})
+		}
+	}
+	// set elements namespaces, local elements being qualified according to their form
+	local := ""
+	if doc.ElementFormDefault == "qualified" {
+		local = doc.TargetNamespace
+	}
+	for _, e := range doc.Elements {
+		s.elements[e.Name] = e
+		e.ns = doc.TargetNamespace
+		setNamespaces(e.ComplexType, doc.TargetNamespace, local)
+	}
+	for _, t := range doc.ComplexTypes {
+		s.complexTypes[t.Name] = t
+		setNamespaces(t, doc.TargetNamespace, local)
+	}
+	for _, t := range doc.SimpleTypes {
+		s.simpleTypes[t.Name] = t
+	}
+	for _, g := range doc.Groups {
+		s.groups[g.Name] = g
+		setGroupNamespaces(g, doc.TargetNamespace, local)
+	}
+	for _, g := range doc.AttributeGroups {
+		s.attributeGroups[g.Name] = g
+	}
+	for _, a := range doc.Attributes {
+		s.attributes[a.Name] = a
+	}
+	return doc, nil
+}
+
+// setNamespaces sets the namespace of local elements of a complex type.
+func setNamespaces(t *xsdComplexType, tns string, local string) {
+	if t == nil {
+		return
+	}
+	models := []*xsdModel{&t.xsdModel}
+	for _, content := range []*xsdContent{t.SimpleContent, t.ComplexContent} {
+		if content == nil {
+			continue
+		}
+		for _, d := range []*xsdDerivation{content.Extension, content.Restriction} {
+			if d != nil {
+				models = append(models, &d.xsdModel)
+			}
+		}
+	}
+	for _, m := range models {
+		for _, g := range []*xsdGroup{m.Sequence, m.Choice, m.All, m.Group} {
+			setGroupNamespaces(g, tns, local)
+		}
+	}
+}
+
+func setGroupNamespaces(g *xsdGroup, tns string, local string) {
+	if g == nil {
+		return
+	}
+	for _, e := range g.Elements {
+		switch e.Form {
+		case "qualified":
+			e.ns = tns
+		case "unqualified":
+			e.ns = ""
+		default:
+			e.ns = local
+		}
+		setNamespaces(e.ComplexType, tns, local)
+	}
+	for _, groups := range [][]*xsdGroup{g.Sequences, g.Choices, g.Alls, g.Groups} {
+		for _, child := range groups {
+			setGroupNamespaces(child, tns, local)
+		}
+	}
+}
+
+// root returns the resolved declaration of a root element, or nil if it is not declared.
+func (s *Schema) root(name string) *schemaElement {
+	decl, ok := s.elements[name]
+	if !ok {
+		return nil
+	}
+	return s.element(decl)
+}
+
+// child returns the resolved declaration of a child element, and whether it is a list.
+// A nil parent is an undeclared element, whose children are not declared either.
+func (s *Schema) child(parent *schemaElement, name string) (*schemaElement, bool) {
+	if parent == nil {
+		return nil, false
+	}
+	child, ok := parent.children[name]
+	if !ok {
+		return nil, false
+	}
+	return s.element(child.decl), child.list
+}
+
+// rootNames returns the sorted names of global elements, which can be documents root elements.
+func (s *Schema) rootNames() []string {
+	names := make([]string, 0, len(s.elements))
+	for name := range s.elements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// element returns the resolved element declaration, resolving it on first use.
+func (s *Schema) element(decl *xsdElement) *schemaElement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resolve(decl)
+}
+
+func (s *Schema) resolve(decl *xsdElement) *schemaElement {
+	if e, ok := s.resolved[decl]; ok {
+		return e
+	}
+	e := &schemaElement{decl: decl, content: contentAny, children: make(map[string]*schemaChild)}
+	s.resolved[decl] = e
+	switch {
+	case decl.ComplexType != nil:
+		s.addComplexType(e, decl.ComplexType, make(map[*xsdComplexType]bool))
+	case decl.SimpleType != nil:
+		e.setSimple(s.simpleOf(decl.SimpleType, 0))
+	case decl.Type != "":
+		if t, ok := s.complexType(decl.Type); ok {
+			s.addComplexType(e, t, make(map[*xsdComplexType]bool))
+		} else if st := s.typeOf(decl.Type, 0); st != nil && st.builtin != "anyType" {
+			e.setSimple(st)
+		}
+	}
+	if e.content == contentElements || e.content == contentMixed {
+		e.model = newContentModel(e.particles)
+	}
+	return e
+}
+
+// setSimple sets the element content as text only, of a simple type.
+func (e *schemaElement) setSimple(st *simpleType) {
+	e.content = contentSimple
+	e.simple = st
+	e.cast = st.castType()
+}
+
+// addComplexType adds the content, attributes and children of a complex type, including its base types.
+func (s *Schema) addComplexType(e *schemaElement, t *xsdComplexType, seen map[*xsdComplexType]bool) {
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+	e.content = contentElements
+	for _, content := range []*xsdContent{t.SimpleContent, t.ComplexContent} {
+		if content == nil {
+			continue
+		}
+		for _, d := range []*xsdDerivation{content.Extension, content.Restriction} {
+			if d == nil {
+				continue
+			}
+			if base, ok := s.complexType(d.Base); ok {
+				s.addComplexType(e, base, seen)
+				// a restriction replaces the content model of its base, an extension appends to it
+				if d == content.Restriction && content == t.ComplexContent {
+					e.particles = nil
+				}
+			} else if content == t.SimpleContent {
+				if st := s.typeOf(d.Base, 0); st != nil {
+					e.setSimple(st)
+				} else {
+					e.content = contentAny
+				}
+			}
+			s.addModel(e, &d.xsdModel)
+		}
+		if content.Mixed == "true" {
+			e.content = contentMixed
+		}
+	}
+	if t.Mixed == "true" {
+		e.content = contentMixed
+	}
+	if e.content == contentMixed {
+		e.cast = CastString
+	}
+	s.addModel(e, &t.xsdModel)
+}
+
+func (s *Schema) addModel(e *schemaElement, m *xsdModel) {
+	for _, g := range []*xsdGroup{m.Sequence, m.Choice, m.All, m.Group} {
+		if g != nil {
+			if p := s.addGroup(e, g, 1, 1, make(map[*xsdGroup]bool)); p != nil {
+				e.particles = append(e.particles, p)
+			}
+		}
+	}
+	for _, a := range m.Attributes {
+		s.addAttribute(e, a)
+	}
+	for _, g := range m.AttributeGroups {
+		s.addAttributeGroup(e, g, make(map[*xsdAttributeGroup]bool))
+	}
+	if m.AnyAttribute != nil {
+		e.anyAttrs = true
+	}
+}
+
+// addGroup adds the elements of a model group, with their minimum and maximum occurrences,
+// min and max being the ones of the parent groups, -1 meaning unbounded, and returns the group particle.
+// Elements are lists if their maximum occurrences is more than 1.
+func (s *Schema) addGroup(e *schemaElement, g *xsdGroup, min int, max int, seen map[*xsdGroup]bool) *schemaParticle {
+	particle := &schemaParticle{kind: particleSequence, min: occurs(g.MinOccurs), max: occurs(g.MaxOccurs)}
+	switch g.kind {
+	case "choice":
+		particle.kind = particleChoice
+	case "all":
+		particle.kind = particleAll
+	}
+	min *= particle.min
+	max = mulOccurs(max, particle.max)
+	if g.Ref != "" {
+		ref, ok := s.groups[localName(g.Ref)]
+		if !ok || seen[ref] {
+			return nil
+		}
+		seen[ref] = true
+		defer delete(seen, ref)
+		g = ref
+	}
+	// in a choice, all particles are optional
+	if particle.kind == particleChoice && len(g.particles) > 1 {
+		min = 0
+	}
+	for _, p := range g.particles {
+		switch child := p.(type) {
+		case *xsdAny:
+			e.anyChildren = true
+			particle.add(&schemaParticle{kind: particleAny, min: occurs(child.MinOccurs), max: occurs(child.MaxOccurs)})
+		case *xsdGroup:
+			particle.add(s.addGroup(e, child, min, max, seen))
+		case *xsdElement:
+			particle.add(s.addElement(e, child, min, max))
+		}
+	}
+	return particle
+}
+
+// addElement adds a child element of a model group, and returns its particle.
+func (s *Schema) addElement(e *schemaElement, child *xsdElement, min int, max int) *schemaParticle {
+	name := child.Name
+	decl := child
+	if child.Ref != "" {
+		name = localName(child.Ref)
+		ref, ok := s.elements[name]
+		if !ok {
+			return nil
+		}
+		decl = ref
+	}
+	particle := &schemaParticle{kind: particleElement, name: name, min: occurs(child.MinOccurs), max: occurs(child.MaxOccurs)}
+	cmin := min * particle.min
+	cmax := mulOccurs(max, particle.max)
+	// elements found in multiple places of the content model can occur in each place
+	if c, ok := e.children[name]; ok {
+		if cmin > c.min {
+			c.min = cmin
+		}
+		if c.max < 0 || cmax < 0 {
+			c.max = -1
+		} else {
+			c.max += cmax
+		}
+		c.list = c.max < 0 || c.max > 1
+		return particle
+	}
+	e.children[name] = &schemaChild{decl: decl, min: cmin, max: cmax, list: cmax < 0 || cmax > 1}
+	e.order = append(e.order, name)
+	return particle
+}
+
+func (s *Schema) addAttribute(e *schemaElement, a *xsdAttribute) {
+	name := a.Name
+	if a.Ref != "" {
+		name = localName(a.Ref)
+		if ref, ok := s.attributes[name]; ok {
+			a = &xsdAttribute{Name: name, Type: ref.Type, SimpleType: ref.SimpleType, Default: a.Default, Fixed: a.Fixed, Use: a.Use}
+			if a.Default == "" && a.Fixed == "" {
+				a.Default, a.Fixed = ref.Default, ref.Fixed
+			}
+		}
+	}
+	if a.Use == "prohibited" {
+		return
+	}
+	attr := &schemaAttribute{name: name, required: a.Use == "required"}
+	if a.SimpleType != nil {
+		attr.simple = s.simpleOf(a.SimpleType, 0)
+	} else if a.Type != "" {
+		attr.simple = s.typeOf(a.Type, 0)
+	}
+	attr.cast = attr.simple.castType()
+	if a.Fixed != "" {
+		attr.value, attr.hasDefault = a.Fixed, true
+	} else if a.Default != "" {
+		attr.value, attr.hasDefault = a.Default, true
+	}
+	// derived types can redefine attributes
+	for i, prev := range e.attrs {
+		if prev.name == name {
+			e.attrs[i] = attr
+			return
+		}
+	}
+	e.attrs = append(e.attrs, attr)
+}
+
+func (s *Schema) addAttributeGroup(e *schemaElement, g *xsdAttributeGroup, seen map[*xsdAttributeGroup]bool) {
+	if g.Ref != "" {
+		ref, ok := s.attributeGroups[localName(g.Ref)]
+		if !ok {
+			return
+		}
+		g = ref
+	}
+	if seen[g] {
+		return
+	}
+	seen[g] = true
+	for _, a := range g.Attributes {
+		s.addAttribute(e, a)
+	}
+	for _, child := range g.AttributeGroups {
+		s.addAttributeGroup(e, child, seen)
+	}
+	if g.AnyAttribute != nil {
+		e.anyAttrs = true
+	}
+}
+
+// complexType returns the complex type of a qualified name, if it is not a built-in type.
+func (s *Schema) complexType(qname string) (*xsdComplexType, bool) {
+	if s.isBuiltin(qname) {
+		return nil, false
+	}
+	t, ok := s.complexTypes[localName(qname)]
+	return t, ok
+}
+
+// typeOf returns the simple type of a qualified name, or nil if it is unknown.
+func (s *Schema) typeOf(qname string, depth int) *simpleType {
+	name := localName(qname)
+	if s.isBuiltin(qname) {
+		return &simpleType{builtin: name}
+	}
+	if t, ok := s.simpleTypes[name]; ok {
+		return s.simpleOf(t, depth)
+	}
+	return nil
+}
+
+// simpleOf returns a simple type, with the facets of all its restrictions.
+// Lists and unions are strings.
+func (s *Schema) simpleOf(t *xsdSimpleType, depth int) *simpleType {
+	r := t.Restriction
+	if r == nil || depth > 32 {
+		return &simpleType{builtin: "string"}
+	}
+	var base *simpleType
+	if r.SimpleType != nil {
+		base = s.simpleOf(r.SimpleType, depth+1)
+	} else {
+		base = s.typeOf(r.Base, depth+1)
+	}
+	res := &simpleType{builtin: "anySimpleType"}
+	if base != nil {
+		res.builtin = base.builtin
+		res.facets = base.facets
+	}
+	res.facets = append([]*xsdRestriction{r}, res.facets...)
+	return res
+}
+
+// isBuiltin returns true if a qualified name is in the XML Schema namespace.
+func (s *Schema) isBuiltin(qname string) bool {
+	prefix, _, ok := strings.Cut(qname, ":")
+	if !ok {
+		prefix = ""
+	}
+	return s.xsPrefixes[prefix]
+}
+
+// occurs returns the value of minOccurs or maxOccurs, 1 by default and -1 for unbounded.
+func occurs(value string) int {
+	if value == "unbounded" {
+		return -1
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 1
+	}
+	return n
+}
+
+// mulOccurs multiplies maximum occurrences, -1 being unbounded.
+func mulOccurs(a int, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	if a < 0 || b < 0 {
+		return -1
+	}
+	return a * b
+}
+
+// castType returns the cast type of a simple type, or "" if unknown.
+func (t *simpleType) castType() string {
+	if t == nil {
+		return ""
+	}
+	if typ, ok := xsdTypes[t.builtin]; ok {
+		return typ
+	}
+	return CastString
+}
+
+func localName(qname string) string {
+	if i := strings.LastIndexByte(qname, ':'); i >= 0 {
+		return qname[i+1:]
+	}
+	return qname
+}
+
+// textCast returns the cast type of the element text, or "" if unknown.
+func (e *schemaElement) textCast() string {
+	if e == nil {
+		return ""
+	}
+	return e.cast
+}
+
+// attrCast returns the cast type of an attribute, or "" if unknown.
+func (e *schemaElement) attrCast(name string) string {
+	if e == nil {
+		return ""
+	}
+	for _, a := range e.attrs {
+		if a.name == name {
+			return a.cast
+		}
+	}
+	return ""
+}
+
+// addDefaults returns the attributes with the missing attributes having a default or fixed value.
+func (e *schemaElement) addDefaults(attrs []xml.Attr) []xml.Attr {
+	if e == nil {
+		return attrs
+	}
+	res := attrs
+	for _, a := range e.attrs {
+		if !a.hasDefault {
+			continue
+		}
+		found := false
+		for _, attr := range attrs {
+			if attr.Name.Local == a.name && attr.Name.Space != xmlnsPrefix {
+				found = true
+				break
+			}
+		}
+		if !found {
+			if len(res) == len(attrs) {
+				res = append([]xml.Attr{}, attrs...)
+			}
+			res = append(res, xml.Attr{Name: xml.Name{Local: a.name}, Value: a.value})
+		}
+	}
+	return res
+}
@@ -0,0 +1,140 @@
+package xqml
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testXsd = `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:t="urn:t" targetNamespace="urn:t">
+  <xs:element name="r">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="id" type="xs:string"/>
+        <xs:element name="n" type="xs:int" minOccurs="0" maxOccurs="unbounded"/>
+        <xs:element ref="t:item" maxOccurs="3"/>
+        <xs:choice maxOccurs="unbounded">
+          <xs:element name="a" type="t:price"/>
+          <xs:element name="b" type="xs:boolean"/>
+        </xs:choice>
+        <xs:element name="one" type="t:one"/>
+      </xs:sequence>
+      <xs:attribute name="version" type="xs:decimal" default="1.0"/>
+      <xs:attributeGroup ref="t:common"/>
+    </xs:complexType>
+  </xs:element>
+  <xs:element name="item">
+    <xs:complexType>
+      <xs:simpleContent>
+        <xs:extension base="xs:long">
+          <xs:attribute name="code" type="xs:string"/>
+          <xs:attribute name="kind" type="xs:string" fixed="x"/>
+        </xs:extension>
+      </xs:simpleContent>
+    </xs:complexType>
+  </xs:element>
+  <xs:simpleType name="price">
+    <xs:restriction base="xs:decimal">
+      <xs:minInclusive value="0"/>
+    </xs:restriction>
+  </xs:simpleType>
+  <xs:complexType name="base">
+    <xs:sequence>
+      <xs:element name="v" type="xs:double"/>
+    </xs:sequence>
+  </xs:complexType>
+  <xs:complexType name="one">
+    <xs:complexContent>
+      <xs:extension base="t:base">
+        <xs:sequence>
+          <xs:element name="w" type="xs:string" maxOccurs="2"/>
+        </xs:sequence>
+      </xs:extension>
+    </xs:complexContent>
+  </xs:complexType>
+  <xs:attributeGroup name="common">
+    <xs:attribute name="lang" type="xs:language" default="en"/>
+  </xs:attributeGroup>
+</xs:schema>`
+
+func Test_Schema(t *testing.T) {
+	schema, err := LoadSchema(strings.NewReader(testXsd))
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	testSchema(t, schema, `<r><id>007</id><n>1</n><item code="1">5</item><a>01.50</a><one><v>1</v><w>1</w></one></r>`,
+		`{"r":{"@lang":"en","@version":1.0,"a":[1.50],"id":"007","item":[{"#text":5,"@code":"1","@kind":"x"}],"n":[1],"one":{"v":1,"w":["1"]}}}`)
+	testSchema(t, schema, `<r version="2"><n>x</n><b>1</b><b>false</b><other>1</other></r>`,
+		`{"r":{"@lang":"en","@version":2,"b":[true,false],"n":["x"],"other":1}}`)
+	// namespaces
+	testSchema(t, schema, `<t:r xmlns:t="urn:t" lang="fr"><t:n>1</t:n></t:r>`,
+		`{"urn:t:r":{"@lang":"fr","@version":1.0,"@xmlns:t":"urn:t","urn:t:n":[1]}}`)
+	// unknown root, and global elements in undeclared elements
+	testSchema(t, schema, `<x><n>1</n><n>2</n></x>`, `{"x":{"n":[1,2]}}`)
+	testSchema(t, schema, `<x><item>5</item></x>`, `{"x":{"item":5}}`)
+	// float infinities and NaN, which can't be encoded to JSON, are kept as strings
+	testSchema(t, schema, `<r><id>1</id><one><v>INF</v><w>NaN</w></one></r>`,
+		`{"r":{"@lang":"en","@version":1.0,"id":"1","one":{"v":"INF","w":["NaN"]}}}`)
+	// schema lists don't change ForceList
+	x := NewDecoder(strings.NewReader(`<r><n>1</n></r><r><n>1</n></r>`))
+	x.Partials = true
+	x.Schema = schema
+	for _, rjson := range []string{`{"r":{"@lang":"en","@version":1.0,"n":[1]}}`, `{"r":{"n":1}}`} {
+		var v any
+		err = x.Decode(&v)
+		if err != nil {
+			t.Errorf("ERROR: %v", err)
+		}
+		if res := Stringify(v); res != rjson {
+			t.Errorf("ERROR: received %s\n", res)
+		}
+		x.Schema = nil
+	}
+}
+
+func Test_SchemaFile(t *testing.T) {
+	dir := t.TempDir()
+	main := `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:include schemaLocation="types/types.xsd"/>
+  <xs:import namespace="http://www.w3.org/XML/1998/namespace" schemaLocation="http://www.w3.org/2001/xml.xsd"/>
+  <xs:element name="r" type="rtype"/>
+</xs:schema>`
+	types := `<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema">
+  <xsd:complexType name="rtype">
+    <xsd:sequence maxOccurs="unbounded">
+      <xsd:element name="e" type="xsd:unsignedInt"/>
+    </xsd:sequence>
+  </xsd:complexType>
+</xsd:schema>`
+	_ = os.Mkdir(filepath.Join(dir, "types"), 0o755)
+	_ = os.WriteFile(filepath.Join(dir, "main.xsd"), []byte(main), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "types", "types.xsd"), []byte(types), 0o644)
+	schema, err := LoadSchemaFile(filepath.Join(dir, "main.xsd"))
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+	testSchema(t, schema, `<r><e>1</e></r>`, `{"r":{"e":[1]}}`)
+	_, err = LoadSchemaFile(filepath.Join(dir, "missing.xsd"))
+	if err == nil {
+		t.Errorf("ERROR: expected error")
+	}
+}
+
+func testSchema(t *testing.T, schema *Schema, src string, rjson string) {
+	t.Logf("")
+	t.Logf("xml => json: %s => %s\n", src, rjson)
+	x := NewDecoder(strings.NewReader(src))
+	x.Schema = schema
+	var v any
+	err := x.Decode(&v)
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+	if res := Stringify(v); res != rjson {
+		t.Errorf("ERROR: received %s\n", res)
+	}
+}